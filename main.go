@@ -1,21 +1,44 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	cli "github.com/enrichman/kubectl-rancher_migrate/pkg/cli"
 )
 
 func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		cancel()
+		// restore the default behavior: a second interrupt will terminate the process immediately
+		signal.Stop(signals)
+		fmt.Fprintln(os.Stderr, "Interrupted: completing the current principal before exiting (interrupt again to force exit)")
+	}()
+
 	rootCmd, err := cli.NewRootCmd()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	if err := rootCmd.Execute(); err != nil {
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+
+		var exitErr *cli.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+		os.Exit(cli.ExitCodeError)
 	}
 }
@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	"github.com/fatih/color"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+var errAborted = errors.New("aborted")
+
+const interactiveHelp = `Commands:
+  <n>[,<n>...]  toggle the items (i.e. 1,3,5-8)
+  a / n         select all / none of the listed items
+  /<text>       list only the items containing the text (/ to list all)
+  d <n>         show the details of an item
+  c             continue with the selected items
+  q             quit`
+
+// ConfirmOptions are the flags of the confirmation of the commands updating the resources
+type ConfirmOptions struct {
+	// Yes skips the confirmation prompt
+	Yes bool
+	// Interactive lets the user select the items of the plan before the confirmation
+	Interactive bool
+}
+
+func (o *ConfirmOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVarP(&o.Yes, "yes", "y", false, "do not ask for confirmation before updating the resources")
+	fs.BoolVarP(
+		&o.Interactive, "interactive", "i", false,
+		"select the principals to update from the list of the plan, with search and details",
+	)
+}
+
+// Confirm asks to confirm the plan, after the interactive selection of its items.
+// It returns the confirmed plan, or errAborted if the user did not confirm it.
+// Without a terminal the confirmation is required with --yes.
+func (o *ConfirmOptions) Confirm(in io.Reader, out io.Writer, plan migrations.Plan) (migrations.Plan, error) {
+	if len(plan.IDs()) == 0 {
+		return plan, nil
+	}
+
+	if o.Interactive {
+		if !isTerminal(in) {
+			return nil, fmt.Errorf("--interactive requires a terminal")
+		}
+
+		ids, err := selectItems(in, out, plan)
+		if err != nil {
+			return nil, err
+		}
+		plan = plan.Select(ids)
+
+		if len(ids) == 0 {
+			fmt.Fprintln(out, "No items selected.")
+			return plan, nil
+		}
+	}
+
+	if o.Yes {
+		return plan, nil
+	}
+
+	if !isTerminal(in) {
+		return nil, fmt.Errorf("confirmation required: use --yes to %s without a terminal", plan.Operation())
+	}
+
+	var counts []string
+	for _, count := range plan.Counts() {
+		counts = append(counts, fmt.Sprintf("%d %s", count.Count, count.Kind))
+	}
+	fmt.Fprintf(out, "\nThe %s will update %s.\n", plan.Operation(), strings.Join(counts, ", "))
+
+	fmt.Fprint(out, "Continue? [y/N] ")
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return plan, nil
+	}
+	return nil, errAborted
+}
+
+// selectItems lists the items of the plan, letting the user toggle, search and inspect them.
+// All the items are initially selected.
+func selectItems(in io.Reader, out io.Writer, plan migrations.Plan) ([]string, error) {
+	ids := plan.IDs()
+
+	summaries := make([]string, len(ids))
+	selected := make([]bool, len(ids))
+	for i, id := range ids {
+		summaries[i] = plan.Summary(id)
+		selected[i] = true
+	}
+
+	var search string
+	scanner := bufio.NewScanner(in)
+
+	for {
+		// listed are the indexes of the items matching the search
+		var listed []int
+		count := 0
+		for i := range ids {
+			if selected[i] {
+				count++
+			}
+			if strings.Contains(strings.ToLower(summaries[i]), strings.ToLower(search)) {
+				listed = append(listed, i)
+			}
+		}
+
+		fmt.Fprintf(out, "\nSelect the principals to %s (%d of %d selected", plan.Operation(), count, len(ids))
+		if search != "" {
+			fmt.Fprintf(out, ", search %q", search)
+		}
+		fmt.Fprintln(out, ")")
+
+		for _, i := range listed {
+			check := "[ ]"
+			if selected[i] {
+				check = color.GreenString("[x]")
+			}
+			fmt.Fprintf(out, "%s %3d) %s\n", check, i+1, summaries[i])
+		}
+
+		fmt.Fprint(out, "(h for help) > ")
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, errAborted
+		}
+		input := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case input == "":
+		case input == "h" || input == "?":
+			fmt.Fprintln(out, interactiveHelp)
+		case input == "c":
+			var result []string
+			for i, id := range ids {
+				if selected[i] {
+					result = append(result, id)
+				}
+			}
+			return result, nil
+		case input == "q":
+			return nil, errAborted
+		case input == "a" || input == "n":
+			for _, i := range listed {
+				selected[i] = input == "a"
+			}
+		case strings.HasPrefix(input, "/"):
+			search = strings.TrimPrefix(input, "/")
+		case strings.HasPrefix(input, "d "):
+			n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(input, "d ")))
+			if err != nil || n < 1 || n > len(ids) {
+				fmt.Fprintf(out, "invalid item '%s'\n", strings.TrimPrefix(input, "d "))
+				continue
+			}
+			plan.PrintDetail(out, ids[n-1])
+		default:
+			indexes, err := parseItems(input, len(ids))
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			for _, i := range indexes {
+				selected[i] = !selected[i]
+			}
+		}
+	}
+}
+
+// parseItems returns the indexes of the items of a list of numbers and ranges (i.e. "1,3,5-8")
+func parseItems(input string, total int) ([]int, error) {
+	var indexes []int
+
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+
+		first, last, isRange := strings.Cut(part, "-")
+		if !isRange {
+			last = first
+		}
+
+		from, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid item '%s', type h for help", part)
+		}
+		to, err := strconv.Atoi(strings.TrimSpace(last))
+		if err != nil {
+			return nil, fmt.Errorf("invalid item '%s', type h for help", part)
+		}
+
+		if from < 1 || to > total || from > to {
+			return nil, fmt.Errorf("invalid item '%s': items are from 1 to %d", part, total)
+		}
+
+		for n := from; n <= to; n++ {
+			indexes = append(indexes, n-1)
+		}
+	}
+
+	return indexes, nil
+}
+
+// isTerminal returns true if the reader is an interactive terminal
+func isTerminal(in io.Reader) bool {
+	f, ok := in.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseItems(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		total   int
+		want    []int
+		wantErr bool
+	}{
+		{name: "single item", input: "1", total: 3, want: []int{0}},
+		{name: "list", input: "1,3", total: 3, want: []int{0, 2}},
+		{name: "range", input: "2-4", total: 5, want: []int{1, 2, 3}},
+		{name: "list and ranges with spaces", input: " 1, 3 - 4 ,5-5", total: 5, want: []int{0, 2, 3, 4}},
+		{name: "last item", input: "5", total: 5, want: []int{4}},
+		{name: "zero", input: "0", total: 5, wantErr: true},
+		{name: "out of range", input: "6", total: 5, wantErr: true},
+		{name: "range out of range", input: "4-6", total: 5, wantErr: true},
+		{name: "reversed range", input: "3-1", total: 5, wantErr: true},
+		{name: "open range", input: "3-", total: 5, wantErr: true},
+		{name: "negative", input: "-1", total: 5, wantErr: true},
+		{name: "not a number", input: "a", total: 5, wantErr: true},
+		{name: "empty item", input: "1,,2", total: 5, wantErr: true},
+		{name: "empty", input: "", total: 5, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseItems(tt.input, tt.total)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseItems(%q, %d) expected error, got %v", tt.input, tt.total, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseItems(%q, %d) unexpected error: %v", tt.input, tt.total, err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseItems(%q, %d) = %v, want %v", tt.input, tt.total, got, tt.want)
+			}
+		})
+	}
+}
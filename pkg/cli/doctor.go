@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	pass = color.New(color.FgGreen).SprintFunc()
+	fail = color.New(color.FgRed).SprintFunc()
+)
+
+// NewMigrationDoctorCmd returns the command checking the permissions and the connectivity needed by a Migration
+func NewMigrationDoctorCmd(c *client.RancherClient, m migrations.Migration) *cobra.Command {
+	return &cobra.Command{
+		Use:          "doctor",
+		Short:        "doctor",
+		Long:         m.ID() + ` preflight check of the permissions and of the connectivity needed by the migration`,
+		SilenceUsage: true,
+		Annotations: map[string]string{
+			skipInitAnnotation: "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logging.Println("# Permissions")
+			results := checkPermissions(cmd.Context(), c, append(m.Permissions(), lockPermissions...))
+			failed := printCheckResults(results)
+
+			logging.Println("\n# Connectivity")
+			results = m.Preflight(cmd.Context(), c, cmd.Flags())
+			failed += printCheckResults(results)
+
+			if failed > 0 {
+				return fmt.Errorf("%d checks failed", failed)
+			}
+
+			logging.Println("\nAll checks passed.")
+			return nil
+		},
+	}
+}
+
+// checkPermissions runs a SelfSubjectAccessReview for every permission
+func checkPermissions(ctx context.Context, c *client.RancherClient, permissions []migrations.Permission) []migrations.CheckResult {
+	results := make([]migrations.CheckResult, 0, len(permissions))
+
+	for _, permission := range permissions {
+		name := fmt.Sprintf("%s %s", permission.Verb, permission.Resource)
+		if permission.Group != "" {
+			name = fmt.Sprintf("%s %s.%s", permission.Verb, permission.Resource, permission.Group)
+		}
+		if permission.Namespace != "" {
+			name = fmt.Sprintf("%s in namespace %s", name, permission.Namespace)
+		}
+
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:      permission.Verb,
+					Group:     permission.Group,
+					Resource:  permission.Resource,
+					Namespace: permission.Namespace,
+				},
+			},
+		}
+
+		review, err := c.Kube.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err == nil && !review.Status.Allowed {
+			err = fmt.Errorf("denied")
+			if review.Status.Reason != "" {
+				err = fmt.Errorf("denied: %s", review.Status.Reason)
+			}
+		}
+
+		results = append(results, migrations.CheckResult{Name: name, Err: err})
+	}
+
+	return results
+}
+
+// printCheckResults prints the checklist of the results, and returns the number of failed checks
+func printCheckResults(results []migrations.CheckResult) int {
+	var failed int
+
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			slog.Error("check failed", "check", result.Name, "error", result.Err)
+			logging.Printf("[%s] %s: %s\n", fail("FAIL"), result.Name, result.Err)
+			continue
+		}
+
+		slog.Info("check passed", "check", result.Name)
+		logging.Printf("[%s] %s\n", pass("PASS"), result.Name)
+	}
+
+	return failed
+}
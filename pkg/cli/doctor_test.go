@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	kube := fake.NewSimpleClientset()
+	kube.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+
+		switch {
+		case attributes.Resource == "secrets":
+			return true, nil, errors.New("connection refused")
+		case attributes.Verb == "delete":
+			review.Status.Reason = "no RBAC policy matched"
+		case attributes.Verb == "watch":
+		default:
+			review.Status.Allowed = true
+		}
+		return true, review, nil
+	})
+
+	results := checkPermissions(context.Background(), &client.RancherClient{Kube: kube}, []migrations.Permission{
+		{Verb: "list", Group: "management.cattle.io", Resource: "users"},
+		{Verb: "get", Resource: "secrets", Namespace: "cattle-global-data"},
+		{Verb: "delete", Group: "management.cattle.io", Resource: "projectroletemplatebindings"},
+		{Verb: "watch", Group: "management.cattle.io", Resource: "tokens"},
+	})
+
+	var (
+		names []string
+		errs  []string
+	)
+	for _, result := range results {
+		names = append(names, result.Name)
+		errMsg := ""
+		if result.Err != nil {
+			errMsg = result.Err.Error()
+		}
+		errs = append(errs, errMsg)
+	}
+
+	wantNames := []string{
+		"list users.management.cattle.io",
+		"get secrets in namespace cattle-global-data",
+		"delete projectroletemplatebindings.management.cattle.io",
+		"watch tokens.management.cattle.io",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("checkPermissions() names = %v, want %v", names, wantNames)
+	}
+
+	wantErrors := []string{"", "connection refused", "denied: no RBAC policy matched", "denied"}
+	if !reflect.DeepEqual(errs, wantErrors) {
+		t.Errorf("checkPermissions() errors = %q, want %q", errs, wantErrors)
+	}
+}
+
+func TestPrintCheckResults(t *testing.T) {
+	var out bytes.Buffer
+	output := logging.Output
+	logging.Output = &out
+	t.Cleanup(func() { logging.Output = output })
+
+	failed := printCheckResults([]migrations.CheckResult{
+		{Name: "activedirectory authconfig found"},
+		{Name: "LDAP bind to dc1.example.com:636", Err: errors.New("invalid credentials")},
+		{Name: "list users.management.cattle.io", Err: errors.New("denied")},
+	})
+
+	if failed != 2 {
+		t.Errorf("printCheckResults() = %d, want 2 failed checks", failed)
+	}
+	if got := out.String(); !strings.Contains(got, "LDAP bind to dc1.example.com:636: invalid credentials") ||
+		strings.Count(got, "FAIL") != 2 || strings.Count(got, "PASS") != 1 {
+		t.Errorf("printCheckResults() output:\n%s", got)
+	}
+}
@@ -0,0 +1,20 @@
+package cli
+
+const (
+	ExitCodeError        = 1
+	ExitCodeVerifyFailed = 2
+)
+
+// ExitError is an error terminating the command with a specific exit code
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
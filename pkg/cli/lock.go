@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	"github.com/fatih/color"
+	"github.com/spf13/pflag"
+)
+
+// lockPermissions is the access needed to hold the lock of the commands updating the resources
+var lockPermissions = []migrations.Permission{
+	{Verb: "get", Group: "coordination.k8s.io", Resource: "leases", Namespace: client.LockNamespace},
+	{Verb: "create", Group: "coordination.k8s.io", Resource: "leases", Namespace: client.LockNamespace},
+	{Verb: "update", Group: "coordination.k8s.io", Resource: "leases", Namespace: client.LockNamespace},
+	{Verb: "delete", Group: "coordination.k8s.io", Resource: "leases", Namespace: client.LockNamespace},
+}
+
+// LockOptions are the flags of the lock held by the commands updating the resources
+type LockOptions struct {
+	// ForceUnlock takes over the lock held by another command
+	ForceUnlock bool
+}
+
+func (o *LockOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(
+		&o.ForceUnlock, "force-unlock", false,
+		"take over the lock held by another command, only if it is not running anymore (i.e. it was killed)",
+	)
+}
+
+// Acquire acquires the lock for the operation, returning the context canceled if the lock is lost and its release
+func (o *LockOptions) Acquire(ctx context.Context, c *client.RancherClient, operation string) (context.Context, func(), error) {
+	holder := holderIdentity()
+
+	if o.ForceUnlock {
+		lock, err := c.GetLock(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if lock != nil && lock.Holder != holder {
+			logging.Printf("%s: taking over the lock %s\n", color.YellowString("warning"), lock)
+		}
+	}
+
+	return c.AcquireLock(ctx, holder, operation, o.ForceUnlock)
+}
+
+// lockError adds the cause of the cancellation to the error, if the lock was lost
+func lockError(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); err != nil && errors.Is(cause, client.ErrLockLost) {
+		return fmt.Errorf("%w (%s)", err, cause)
+	}
+	return err
+}
+
+// holderIdentity identifies the process holding the lock, i.e. "user@host (pid 123)"
+func holderIdentity() string {
+	username := "unknown"
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("%s@%s (pid %d)", username, hostname, os.Getpid())
+}
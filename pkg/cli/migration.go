@@ -0,0 +1,479 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// skipInitAnnotation marks the subcommands that run without the Init of the Migration
+const skipInitAnnotation = "rancher-migrate/skip-init"
+
+// NewMigrationCmd returns the command of a registered Migration, with its check, migrate, rollback, verify and doctor subcommands
+func NewMigrationCmd(c *client.RancherClient, m migrations.Migration) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          m.ID(),
+		Short:        m.Description(),
+		Long:         `Handle ` + m.ID() + ` migration: ` + m.Description(),
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Annotations[skipInitAnnotation] != "" {
+				return nil
+			}
+			return m.Init(cmd.Context(), c, cmd.Flags())
+		},
+	}
+
+	m.AddFlags(cmd.PersistentFlags())
+
+	cmd.AddCommand(
+		NewMigrationCheckCmd(m),
+		NewMigrationPlanCmd(m),
+		NewMigrationUpdateCmd(c, m, migrations.OperationMigrate),
+		NewMigrationUpdateCmd(c, m, migrations.OperationRollback),
+		NewMigrationVerifyCmd(m),
+		NewMigrationDoctorCmd(c, m),
+	)
+
+	if inspector, ok := m.(migrations.Inspector); ok {
+		cmd.AddCommand(NewMigrationInspectCmd(m, inspector))
+	}
+	if resolver, ok := m.(migrations.IdentityResolver); ok {
+		cmd.AddCommand(NewMigrationResolveCmd(m, resolver))
+	}
+	if maintainer, ok := m.(migrations.Maintainer); ok {
+		cmd.AddCommand(NewMigrationRestoreMaintenanceCmd(c, m, maintainer))
+	}
+
+	return cmd
+}
+
+func NewMigrationCheckCmd(m migrations.Migration) *cobra.Command {
+	var exportMapping string
+
+	cmd := &cobra.Command{
+		Use:          "check",
+		Short:        "check",
+		Long:         m.ID() + ` migration check`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			discovery, err := m.Discover(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			discovery.Print()
+
+			if exportMapping == "" {
+				return nil
+			}
+			return m.(migrations.MappingExporter).ExportMapping(cmd.Context(), discovery, exportMapping)
+		},
+	}
+	addFromDirFlag(cmd)
+
+	if _, ok := m.(migrations.MappingExporter); ok {
+		cmd.Flags().StringVar(
+			&exportMapping, "export-mapping", "",
+			"CSV file where the resolved identity of every principal is exported, for review and for the --mapping flag",
+		)
+	}
+
+	return cmd
+}
+
+// NewMigrationPlanCmd returns the command printing the Plan of a migration or of a rollback,
+// that can be saved and applied later with the --plan flag of the migrate and rollback commands
+func NewMigrationPlanCmd(m migrations.Migration) *cobra.Command {
+	var (
+		rollback bool
+		output   string
+	)
+	selectionOpts := &SelectionOptions{}
+
+	cmd := &cobra.Command{
+		Use:          "plan [principal...]",
+		Short:        "plan",
+		Long:         m.ID() + ` migration plan (use --from-dir to plan offline from exported resources)`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			operation := migrations.OperationMigrate
+			if rollback {
+				operation = migrations.OperationRollback
+			}
+
+			selection, err := selectionOpts.ToSelection(args)
+			if err != nil {
+				return err
+			}
+
+			discovery, err := m.Discover(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			plan, err := planOperation(cmd, m, operation, discovery, selection)
+			if err != nil {
+				return err
+			}
+
+			plan.Print()
+
+			if output == "" {
+				return nil
+			}
+
+			err = plan.Save(output)
+			if err != nil {
+				return fmt.Errorf("cannot save plan: %w", err)
+			}
+
+			logging.Printf("\nPlan saved in '%s', apply it with:\n\t%s %s %s --plan %s\n", output, cmd.Root().Name(), m.ID(), operation, output)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&rollback, "rollback", false, "plan the rollback instead of the migration")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file where the plan is saved")
+	selectionOpts.AddFlags(cmd.Flags())
+	addFromDirFlag(cmd)
+
+	return cmd
+}
+
+// addFromDirFlag adds the flag running the command offline, on the resources exported in a directory
+func addFromDirFlag(cmd *cobra.Command) {
+	cmd.Flags().String(
+		fromDirFlag, "",
+		"directory with the exported users, PRTBs, CRTBs and tokens (kubectl get -o yaml) to run offline",
+	)
+}
+
+// NewMigrationVerifyCmd returns the command verifying a Migration, exiting with ExitCodeVerifyFailed
+// if any of the checks failed
+func NewMigrationVerifyCmd(m migrations.Migration) *cobra.Command {
+	return &cobra.Command{
+		Use:          "verify",
+		Short:        "verify",
+		Long:         m.ID() + ` migration verification (exit code 0 if passed, 1 on errors, 2 if failed)`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			results, err := m.Verify(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			failed := printCheckResults(results)
+			if failed > 0 {
+				return &ExitError{
+					Code: ExitCodeVerifyFailed,
+					Err:  fmt.Errorf("verification failed: %d checks failed", failed),
+				}
+			}
+
+			logging.Println("\nVerification passed.")
+			return nil
+		},
+	}
+}
+
+// NewMigrationInspectCmd returns the command showing the resources referencing an identity
+func NewMigrationInspectCmd(m migrations.Migration, inspector migrations.Inspector) *cobra.Command {
+	return &cobra.Command{
+		Use:          "inspect <principal|username|DN|GUID>",
+		Short:        "inspect",
+		Long:         m.ID() + ` inspection of all the resources referencing an identity`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return inspector.Inspect(cmd.Context(), args[0])
+		},
+	}
+}
+
+// NewMigrationResolveCmd returns the command translating the identities provided as arguments or in a file
+func NewMigrationResolveCmd(m migrations.Migration, resolver migrations.IdentityResolver) *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:          "resolve [identity...]",
+		Short:        "resolve",
+		Long:         m.ID() + ` translation of the identities between all their formats`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			identities := args
+
+			if file != "" {
+				fromFile, err := readLines(file)
+				if err != nil {
+					return err
+				}
+				identities = append(identities, fromFile...)
+			}
+
+			if len(identities) == 0 {
+				return fmt.Errorf("no identities provided")
+			}
+
+			return resolver.ResolveIdentities(cmd.Context(), identities)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "file with one identity per line ('-' for stdin)")
+
+	return cmd
+}
+
+// NewMigrationRestoreMaintenanceCmd returns the command restoring the logins of a maintenance that was not restored,
+// i.e. because the command updating the resources was killed
+func NewMigrationRestoreMaintenanceCmd(c *client.RancherClient, m migrations.Migration, maintainer migrations.Maintainer) *cobra.Command {
+	lockOpts := &LockOptions{}
+
+	cmd := &cobra.Command{
+		Use:          "restore-maintenance",
+		Short:        "restore-maintenance",
+		Long:         m.ID() + ` restore of the logins restricted by a maintenance that was not restored`,
+		SilenceUsage: true,
+		Annotations: map[string]string{
+			skipInitAnnotation: "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// the lock is held so that the maintenance of a running command is not restored
+			ctx, release, err := lockOpts.Acquire(cmd.Context(), c, m.ID()+" restore-maintenance")
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			return lockError(ctx, maintainer.RestoreMaintenance(ctx, c))
+		},
+	}
+	lockOpts.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// NewMigrationUpdateCmd returns the migrate or the rollback command of a Migration
+func NewMigrationUpdateCmd(c *client.RancherClient, m migrations.Migration, operation string) *cobra.Command {
+	var planFile string
+	updateOpts := &UpdateOptions{}
+	selectionOpts := &SelectionOptions{}
+	confirmOpts := &ConfirmOptions{}
+	lockOpts := &LockOptions{}
+
+	long := m.ID() + ` migration`
+	if operation == migrations.OperationRollback {
+		long = m.ID() + ` rollback`
+	}
+
+	cmd := &cobra.Command{
+		Use:          operation,
+		Short:        operation,
+		Long:         long,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			var plan migrations.Plan
+
+			selection, err := selectionOpts.ToSelection(args)
+			if err != nil {
+				return err
+			}
+
+			if updateOpts.Watch > 0 {
+				if updateOpts.DryRun {
+					return fmt.Errorf("cannot use --watch with --dry-run")
+				}
+				if !selection.Empty() || planFile != "" {
+					return fmt.Errorf("--watch migrates all the new principals: it cannot be used with a selection or --plan")
+				}
+			}
+
+			// without other selectors, the principals of the waves are selected
+			waveIDs, err := updateOpts.LoadWaves()
+			if err != nil {
+				return err
+			}
+			if len(waveIDs) > 0 && selection.Empty() && planFile == "" {
+				selection.IDs = waveIDs
+			}
+
+			// the lock is held from the discovery of the resources, to plan on resources not being updated
+			if !updateOpts.DryRun {
+				ctx, release, err := lockOpts.Acquire(cmd.Context(), c, m.ID()+" "+operation)
+				if err != nil {
+					return err
+				}
+				defer release()
+				cmd.SetContext(ctx)
+			}
+
+			if updateOpts.RunID == "" {
+				updateOpts.RunID = migrations.NewRunID()
+			}
+
+			if planFile != "" {
+				plan, err = loadPlan(cmd, m, operation, planFile, selection, updateOpts)
+			} else {
+				plan, err = discoverPlan(cmd, m, operation, selection, updateOpts)
+			}
+			if err != nil {
+				return err
+			}
+
+			// a dry run does not update the resources, and is not confirmed
+			if !updateOpts.DryRun {
+				plan, err = confirmOpts.Confirm(cmd.InOrStdin(), cmd.ErrOrStderr(), plan)
+				if err != nil {
+					return err
+				}
+			}
+
+			// the logins are restricted only while the confirmed plan is applied
+			if maintainer, ok := m.(migrations.Maintainer); ok && !updateOpts.DryRun && len(plan.IDs()) > 0 {
+				restore, startErr := maintainer.StartMaintenance(cmd.Context(), updateOpts.ApplyOptions)
+				if startErr != nil {
+					return startErr
+				}
+				defer func() {
+					err = errors.Join(err, restore())
+				}()
+			}
+
+			err = m.Apply(cmd.Context(), plan, updateOpts.ApplyOptions)
+			if err != nil {
+				return lockError(cmd.Context(), err)
+			}
+
+			return updateOpts.Done()
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			// no kubeconfig
+			if c.Rancher == nil {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+
+			err := m.Init(cmd.Context(), c, cmd.Flags())
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+
+			discovery, err := m.Discover(cmd.Context())
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+
+			ids := discovery.Pending()
+			if operation == migrations.OperationRollback {
+				ids = discovery.Migrated()
+			}
+
+			var suggestions []string
+			for _, id := range ids {
+				if !slices.Contains(args, id) {
+					suggestions = append(suggestions, id)
+				}
+			}
+
+			return suggestions, cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	updateOpts.AddFlags(cmd.Flags())
+	selectionOpts.AddFlags(cmd.Flags())
+	confirmOpts.AddFlags(cmd.Flags())
+	lockOpts.AddFlags(cmd.Flags())
+	if operation == migrations.OperationMigrate {
+		cmd.Flags().DurationVar(
+			&updateOpts.Watch, "watch", 0,
+			"after the migration, keep migrating the new principals appearing for this duration (i.e. 30m)",
+		)
+	}
+	cmd.Flags().StringVar(&planFile, "plan", "", "apply a plan saved with the plan command, instead of planning again")
+
+	return cmd
+}
+
+// discoverPlan returns the Plan of the selected (or resumed) principals
+func discoverPlan(cmd *cobra.Command, m migrations.Migration, operation string, selection migrations.Selection, updateOpts *UpdateOptions) (migrations.Plan, error) {
+	if updateOpts.Resume && !selection.Empty() {
+		return nil, fmt.Errorf("cannot select principals with --resume")
+	}
+
+	ids, err := updateOpts.ResumeArgs(operation, selection.IDs)
+	if err != nil {
+		return nil, err
+	}
+	selection.IDs = ids
+
+	discovery, err := m.Discover(cmd.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	if updateOpts.Resume {
+		selection.IDs, err = resumableIDs(operation, discovery, ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return planOperation(cmd, m, operation, discovery, selection)
+}
+
+// resumableIDs returns the resumed principals still to be updated. The ones already updated, i.e. by a wave
+// whose verification failed or was interrupted, are skipped and reported.
+func resumableIDs(operation string, discovery migrations.Discovery, ids []string) ([]string, error) {
+	updatable := discovery.Pending()
+	if operation == migrations.OperationRollback {
+		updatable = discovery.Migrated()
+	}
+
+	var resumable []string
+	for _, id := range ids {
+		if slices.Contains(updatable, id) {
+			resumable = append(resumable, id)
+			continue
+		}
+
+		slog.Warn("resumed principal already updated", "principal", id, "operation", operation)
+		logging.Printf("%s: %s was already updated, verify it with the verify command\n", color.YellowString("skipped"), id)
+	}
+
+	if len(resumable) == 0 {
+		return nil, fmt.Errorf("cannot resume: all the pending principals of the state file were already updated")
+	}
+
+	return resumable, nil
+}
+
+// loadPlan returns the Plan saved in the file, checking that it is a plan of the operation
+func loadPlan(cmd *cobra.Command, m migrations.Migration, operation, path string, selection migrations.Selection, updateOpts *UpdateOptions) (migrations.Plan, error) {
+	if !selection.Empty() || updateOpts.Resume {
+		return nil, fmt.Errorf("cannot select principals or use --resume with --plan")
+	}
+
+	plan, err := m.LoadPlan(cmd.Context(), path)
+	if err != nil {
+		return nil, err
+	}
+
+	if plan.Operation() != operation {
+		return nil, fmt.Errorf("plan file '%s' is a plan of a %s", path, plan.Operation())
+	}
+
+	return plan, nil
+}
+
+// planOperation returns the Plan of the migration or of the rollback of the selected items
+func planOperation(cmd *cobra.Command, m migrations.Migration, operation string, discovery migrations.Discovery, selection migrations.Selection) (migrations.Plan, error) {
+	if operation == migrations.OperationRollback {
+		return m.PlanRollback(cmd.Context(), discovery, selection)
+	}
+	return m.Plan(cmd.Context(), discovery, selection)
+}
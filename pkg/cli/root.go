@@ -1,37 +1,92 @@
 package cli
 
 import (
+	"context"
+	"time"
+
 	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	_ "github.com/enrichman/kubectl-rancher_migrate/pkg/migrations/v1_10_0"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 )
 
+// fromDirFlag is the flag of the subcommands that can run offline, on the resources exported in a directory
+const fromDirFlag = "from-dir"
+
 func NewRootCmd() (*cobra.Command, error) {
+	var (
+		verbosity int
+		logFormat string
+		timeout   time.Duration
+
+		cancelTimeout context.CancelFunc = func() {}
+	)
+
+	// the client is set up before running the subcommands, since the offline ones don't need a kubeconfig
+	c := &client.RancherClient{}
+
+	config, configErr := genericclioptions.NewConfigFlags(true).ToRESTConfig()
+	if configErr == nil {
+		online, err := client.NewRancherClient(config)
+		if err != nil {
+			return nil, err
+		}
+		*c = *online
+	}
+
+	// run the logging and timeout setup of the root command before the hooks of the subcommands
+	cobra.EnableTraverseRunHooks = true
+
 	rootCmd := &cobra.Command{
 		Use:          "kubectl-rancher_migrate",
 		Short:        "kubectl-rancher_migrate",
 		Long:         `Rancher migration tool.`,
 		SilenceUsage: true,
-	}
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if timeout > 0 {
+				ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+				cmd.SetContext(ctx)
+				cancelTimeout = cancel
+			}
 
-	config, err := genericclioptions.NewConfigFlags(true).ToRESTConfig()
-	if err != nil {
-		return nil, err
-	}
+			err := logging.Setup(logFormat, verbosity)
+			if err != nil {
+				return err
+			}
 
-	c, err := client.NewRancherClient(config)
-	if err != nil {
-		return nil, err
-	}
+			if flag := cmd.Flags().Lookup(fromDirFlag); flag != nil && flag.Changed {
+				offline, err := client.NewOfflineRancherClient(flag.Value.String())
+				if err != nil {
+					return err
+				}
+				*c = *offline
+				return nil
+			}
 
-	v1_10_0Cmd, err := NewV1_10_0_Cmd(c)
-	if err != nil {
-		return nil, err
+			return configErr
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			cancelTimeout()
+		},
 	}
 
-	rootCmd.AddCommand(
-		v1_10_0Cmd,
+	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "log verbosity (-v for info, -vv for debug)")
+	rootCmd.PersistentFlags().StringVar(
+		&logFormat, "log-format", logging.FormatText,
+		"log format: text (human readable output, logs on stderr) or json (one event per action on stdout)",
 	)
+	rootCmd.PersistentFlags().DurationVar(
+		&timeout, "timeout", 0,
+		"timeout of the whole command (i.e. 30m), the principal being updated is completed before exiting",
+	)
+
+	rootCmd.AddCommand(NewStatusCmd(c))
+
+	for _, m := range migrations.All() {
+		rootCmd.AddCommand(NewMigrationCmd(c, m))
+	}
 
 	return rootCmd, nil
 }
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	"github.com/spf13/pflag"
+)
+
+// SelectionOptions are the flags selecting the principals of the commands planning a migration or a rollback
+type SelectionOptions struct {
+	migrations.Selection
+
+	// FromFile is a file with the principal IDs to select, one per line
+	FromFile string
+}
+
+func (o *SelectionOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&o.Users, "user", nil, "select the principals of the Rancher users (username or user ID)")
+	fs.StringSliceVar(&o.Clusters, "cluster", nil, "select the principals with bindings or tokens in the clusters (cluster ID)")
+	fs.StringSliceVar(&o.Projects, "project", nil, "select the principals with bindings in the projects (<cluster ID>:<project ID> or project ID)")
+	fs.StringSliceVar(&o.Namespaces, "namespace", nil, "select the principals with bindings in the namespaces")
+	// the DNs contain commas, so these flags must be repeated instead of being comma separated
+	fs.StringArrayVar(&o.OUs, "ou", nil, "select the principals in the subtree of the OU (i.e. OU=Sales,DC=example,DC=com), can be repeated")
+	fs.StringArrayVar(&o.Groups, "ad-group", nil, "select the principals member of the Active Directory group (DN), can be repeated")
+	fs.StringArrayVar(&o.Exclude, "exclude", nil, "exclude the principal (principal ID or username), can be repeated")
+	fs.StringVar(&o.FromFile, "from-file", "", "file with the principal IDs to select, one per line ('-' for stdin)")
+}
+
+// ToSelection returns the Selection of the flags and of the principal IDs provided as arguments
+func (o *SelectionOptions) ToSelection(args []string) (migrations.Selection, error) {
+	selection := o.Selection
+	selection.IDs = append([]string{}, args...)
+
+	if o.FromFile != "" {
+		ids, err := readLines(o.FromFile)
+		if err != nil {
+			return selection, err
+		}
+		selection.IDs = append(selection.IDs, ids...)
+	}
+
+	return selection, nil
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+const (
+	StatusNotApplicable = "not applicable"
+	StatusApplied       = "applied"
+	StatusPending       = "pending"
+)
+
+var statusColors = map[string]func(a ...any) string{
+	StatusNotApplicable: color.New(color.FgBlue).SprintFunc(),
+	StatusApplied:       color.New(color.FgGreen).SprintFunc(),
+	StatusPending:       color.New(color.FgYellow).SprintFunc(),
+}
+
+// NewStatusCmd returns the command printing which registered migrations are pending on the Rancher server
+func NewStatusCmd(c *client.RancherClient) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the migrations pending on the Rancher server",
+		Long: `Show the Rancher version, the enabled auth providers, the holder of the lock of the commands updating the resources,
+and which migrations are applicable, applied or pending.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serverVersion, err := c.ServerVersion(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			providers, err := c.EnabledAuthProviders(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			// the lock is not needed to report the status, i.e. without access to the leases
+			lock, lockErr := c.GetLock(cmd.Context())
+
+			slog.Info("rancher status", "serverVersion", serverVersion, "authProviders", providers)
+			logging.Printf("Rancher version:\t%s\n", serverVersion)
+			logging.Printf("Auth providers:\t\t%s\n", strings.Join(providers, ", "))
+			printLock(lock, lockErr)
+			logging.Println()
+
+			var pending []string
+
+			logging.Println("# Migrations")
+			for _, m := range migrations.All() {
+				status, err := migrationStatus(cmd, c, m, serverVersion)
+				if err != nil {
+					return err
+				}
+
+				if status == StatusPending {
+					pending = append(pending, m.ID())
+				}
+
+				slog.Info("migration status", "migration", m.ID(), "status", status)
+				logging.Printf("%s\t%s\t%s\n", m.ID(), statusColors[status](status), m.Description())
+			}
+
+			logging.Println()
+			if len(pending) == 0 {
+				logging.Println("No migrations pending.")
+				return nil
+			}
+
+			logging.Printf("%d migrations pending: run the check command of each one before migrating, i.e.:\n", len(pending))
+			for _, id := range pending {
+				logging.Printf("\t%s %s check\n", cmd.Root().Name(), id)
+			}
+
+			return nil
+		},
+	}
+}
+
+// migrationStatus returns if the Migration is not applicable to the Rancher server version, applied or pending
+func migrationStatus(cmd *cobra.Command, c *client.RancherClient, m migrations.Migration, serverVersion string) (string, error) {
+	applicable, err := m.Applicable(cmd.Context(), c, serverVersion)
+	if err != nil {
+		return "", err
+	}
+	if !applicable {
+		return StatusNotApplicable, nil
+	}
+
+	applied, err := m.Applied(cmd.Context(), c)
+	if err != nil {
+		return "", err
+	}
+	if applied {
+		return StatusApplied, nil
+	}
+
+	return StatusPending, nil
+}
+
+// printLock prints the holder of the lock of the commands updating the resources, if any,
+// or "unknown" if the lock cannot be read
+func printLock(lock *client.Lock, err error) {
+	if err != nil {
+		slog.Warn("cannot get lock", "error", err)
+		logging.Printf("Lock:\t\t\t%s (%s)\n", color.YellowString("unknown"), err)
+		return
+	}
+
+	if lock == nil {
+		slog.Info("lock status", "locked", false)
+		logging.Printf("Lock:\t\t\t%s\n", color.GreenString("free"))
+		return
+	}
+
+	slog.Info("lock status", "locked", true, "holder", lock.Holder, "operation", lock.Operation, "expired", lock.Expired())
+
+	state := color.YellowString(lock.String())
+	if lock.Expired() {
+		state += " (expired: the holder is not running anymore, the lock can be taken over)"
+	}
+	logging.Printf("Lock:\t\t\t%s\n", state)
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	"github.com/spf13/pflag"
+)
+
+// wavesSeparator separates the waves of a waves file
+const wavesSeparator = "---"
+
+// UpdateOptions are the options of the commands updating the resources (migrate and rollback)
+type UpdateOptions struct {
+	migrations.ApplyOptions
+
+	// Resume continues an interrupted operation from the pending principals of the StateFile
+	Resume bool
+	// WavesFile is a file with the principal IDs of every wave, separated by "---" lines
+	WavesFile string
+}
+
+func (o *UpdateOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.DurationVar(
+		&o.StepTimeout, "step-timeout", 2*time.Minute,
+		"timeout of the update of the resources of a single principal",
+	)
+	fs.StringVar(
+		&o.StateFile, "state-file", "rancher-migrate-state.json",
+		"file where the pending principals are saved when the command is interrupted or fails",
+	)
+	fs.BoolVar(
+		&o.Resume, "resume", false,
+		"resume an interrupted command from the pending principals of the state file",
+	)
+	fs.IntVar(
+		&o.BatchSize, "batch-size", 0,
+		"update the principals in waves of this size, verifying every wave before the next one",
+	)
+	fs.StringVar(
+		&o.WavesFile, "waves", "",
+		"file with the principal IDs of every wave, one per line, with the waves separated by '---' lines",
+	)
+	fs.DurationVar(&o.Pause, "pause", 0, "pause between two waves (i.e. 10m)")
+	fs.BoolVar(&o.Diff, "diff", false, "print the YAML diff of every object before updating it")
+	fs.BoolVar(&o.DryRun, "dry-run", false, "print the YAML diff of every object without updating it")
+	fs.Float64Var(
+		&o.MaxFailureRate, "max-failure-rate", 0,
+		"rate (0-1) of the failed principals of a wave over which the update is stopped",
+	)
+}
+
+// LoadWaves reads the WavesFile, returning the principal IDs of all the waves.
+// An interrupted operation is resumed with the waves of its state file instead.
+func (o *UpdateOptions) LoadWaves() ([]string, error) {
+	if o.WavesFile == "" {
+		return nil, nil
+	}
+
+	if o.BatchSize > 0 || o.Resume {
+		return nil, fmt.Errorf("cannot use --waves with --batch-size or --resume")
+	}
+
+	lines, err := readLines(o.WavesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	wave := []string{}
+
+	for _, line := range append(lines, wavesSeparator) {
+		if line != wavesSeparator {
+			wave = append(wave, line)
+			ids = append(ids, line)
+			continue
+		}
+
+		if len(wave) > 0 {
+			o.Waves = append(o.Waves, wave)
+		}
+		wave = []string{}
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no principals in waves file '%s'", o.WavesFile)
+	}
+
+	return ids, nil
+}
+
+// ResumeArgs returns the pending principals of the state file when resuming, or the provided args
+func (o *UpdateOptions) ResumeArgs(operation string, args []string) ([]string, error) {
+	if !o.Resume {
+		return args, nil
+	}
+
+	if len(args) > 0 {
+		return nil, fmt.Errorf("cannot use principal IDs with --resume")
+	}
+
+	state, err := migrations.LoadState(o.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.Operation != operation {
+		return nil, fmt.Errorf("cannot resume: state file '%s' is for a %s", o.StateFile, state.Operation)
+	}
+
+	if len(state.Pending) == 0 {
+		return nil, fmt.Errorf("cannot resume: no pending principals in state file '%s'", o.StateFile)
+	}
+
+	// the interrupted waves are resumed with their boundaries
+	if len(state.Waves) > 0 {
+		o.Waves = state.Waves
+	}
+
+	return state.Pending, nil
+}
+
+// Done removes the state file of a resumed operation after its completion
+func (o *UpdateOptions) Done() error {
+	if !o.Resume || o.DryRun {
+		return nil
+	}
+	return migrations.RemoveState(o.StateFile)
+}
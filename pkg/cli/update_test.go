@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+)
+
+func TestResumeArgs(t *testing.T) {
+	dir := t.TempDir()
+
+	stateFile := filepath.Join(dir, "state.json")
+	state := migrations.NewState(migrations.OperationMigrate, []string{"a", "b", "c"}, 1, nil)
+	if err := state.Save(stateFile); err != nil {
+		t.Fatal(err)
+	}
+
+	wavesStateFile := filepath.Join(dir, "waves-state.json")
+	state = migrations.NewWavesState(migrations.OperationMigrate, []string{"a"}, [][]string{{"b"}, {"c"}}, nil)
+	if err := state.Save(wavesStateFile); err != nil {
+		t.Fatal(err)
+	}
+
+	completedStateFile := filepath.Join(dir, "completed-state.json")
+	state = migrations.NewState(migrations.OperationMigrate, []string{"a"}, 1, nil)
+	if err := state.Save(completedStateFile); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		opts      UpdateOptions
+		operation string
+		args      []string
+		want      []string
+		wantWaves [][]string
+		wantErr   bool
+	}{
+		{
+			name:      "not resumed",
+			operation: migrations.OperationMigrate,
+			args:      []string{"x"},
+			want:      []string{"x"},
+		},
+		{
+			name:      "resumed",
+			opts:      UpdateOptions{Resume: true, ApplyOptions: migrations.ApplyOptions{StateFile: stateFile}},
+			operation: migrations.OperationMigrate,
+			want:      []string{"b", "c"},
+		},
+		{
+			name:      "resumed with the waves",
+			opts:      UpdateOptions{Resume: true, ApplyOptions: migrations.ApplyOptions{StateFile: wavesStateFile}},
+			operation: migrations.OperationMigrate,
+			want:      []string{"b", "c"},
+			wantWaves: [][]string{{"b"}, {"c"}},
+		},
+		{
+			name:      "resumed with args",
+			opts:      UpdateOptions{Resume: true, ApplyOptions: migrations.ApplyOptions{StateFile: stateFile}},
+			operation: migrations.OperationMigrate,
+			args:      []string{"x"},
+			wantErr:   true,
+		},
+		{
+			name:      "state of another operation",
+			opts:      UpdateOptions{Resume: true, ApplyOptions: migrations.ApplyOptions{StateFile: stateFile}},
+			operation: migrations.OperationRollback,
+			wantErr:   true,
+		},
+		{
+			name:      "no pending principals",
+			opts:      UpdateOptions{Resume: true, ApplyOptions: migrations.ApplyOptions{StateFile: completedStateFile}},
+			operation: migrations.OperationMigrate,
+			wantErr:   true,
+		},
+		{
+			name:      "missing state file",
+			opts:      UpdateOptions{Resume: true, ApplyOptions: migrations.ApplyOptions{StateFile: filepath.Join(dir, "missing.json")}},
+			operation: migrations.OperationMigrate,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.ResumeArgs(tt.operation, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResumeArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResumeArgs() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.opts.Waves, tt.wantWaves) {
+				t.Errorf("waves = %v, want %v", tt.opts.Waves, tt.wantWaves)
+			}
+		})
+	}
+}
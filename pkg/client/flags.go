@@ -0,0 +1,107 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	envPrefix        = "RANCHER_MIGRATE_"
+	ldapFlagPrefix   = "ldap-"
+	ldapConfigFlag   = "ldap-config"
+	ldapConfigSample = `servers: [dc1.example.com, dc2.example.com]
+port: 636
+tls-mode: tls
+ca-file: /etc/ssl/ad-ca.pem
+bind-dn: CN=migration,OU=Service Accounts,DC=example,DC=com
+password-secret: cattle-system:ad-migration-sa:password`
+)
+
+// AddLDAPFlags adds the flags overriding the LDAP connection settings of the activedirectory authconfig
+func AddLDAPFlags(fs *pflag.FlagSet, overrides *LDAPOverrides, configFile *string) {
+	fs.StringSliceVar(&overrides.Servers, "ldap-servers", nil, "LDAP servers")
+	fs.Int64Var(&overrides.Port, "ldap-port", 0, "LDAP port")
+	fs.StringVar(&overrides.TLSMode, "ldap-tls-mode", "", "LDAP TLS mode: none, tls or starttls")
+	fs.StringVar(&overrides.CAFile, "ldap-ca-file", "", "PEM bundle of the CA certificates of the LDAP servers")
+	fs.StringVar(&overrides.BindDN, "ldap-bind-dn", "", "DN of the service account used to bind")
+	fs.DurationVar(&overrides.Timeout, "ldap-timeout", 0, "timeout of the LDAP connection and of every LDAP operation")
+	fs.StringVar(&overrides.PasswordFile, "ldap-password-file", "", "file containing the bind password")
+	fs.StringVar(&overrides.PasswordEnv, "ldap-password-env", "", "environment variable containing the bind password")
+	fs.StringVar(
+		&overrides.PasswordSecret, "ldap-password-secret", "",
+		"Secret containing the bind password, as 'namespace:name[:key]' (default key 'serviceaccountpassword')",
+	)
+	fs.StringVar(
+		configFile, ldapConfigFlag, "",
+		"YAML file with the LDAP flags without the 'ldap-' prefix, i.e.:\n"+ldapConfigSample,
+	)
+}
+
+// LoadLDAPFlags sets the LDAP flags not provided in the command line from the environment variables
+// (i.e. RANCHER_MIGRATE_LDAP_SERVERS for --ldap-servers), and then from the config file.
+func LoadLDAPFlags(fs *pflag.FlagSet) error {
+	var err error
+
+	fs.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || !strings.HasPrefix(f.Name, ldapFlagPrefix) {
+			return
+		}
+
+		envName := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value, found := os.LookupEnv(envName); found {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value of %s: %w", envName, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	configFile, _ := fs.GetString(ldapConfigFlag)
+	if configFile == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("cannot read LDAP config file: %w", err)
+	}
+
+	config := map[string]any{}
+	err = yaml.Unmarshal(b, &config)
+	if err != nil {
+		return fmt.Errorf("cannot parse LDAP config file: %w", err)
+	}
+
+	for key, value := range config {
+		name := ldapFlagPrefix + key
+
+		f := fs.Lookup(name)
+		if f == nil || name == ldapConfigFlag {
+			return fmt.Errorf("unknown key '%s' in LDAP config file", key)
+		}
+		if f.Changed {
+			continue
+		}
+
+		if values, ok := value.([]any); ok {
+			var s []string
+			for _, v := range values {
+				s = append(s, fmt.Sprint(v))
+			}
+			value = strings.Join(s, ",")
+		}
+
+		err = fs.Set(name, fmt.Sprint(value))
+		if err != nil {
+			return fmt.Errorf("invalid value of '%s' in LDAP config file: %w", key, err)
+		}
+	}
+
+	return nil
+}
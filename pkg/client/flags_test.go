@@ -0,0 +1,113 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestLoadLDAPFlags(t *testing.T) {
+	dir := t.TempDir()
+
+	configFile := filepath.Join(dir, "ldap.yaml")
+	config := "servers: [dc1.example.com, dc2.example.com]\nport: 636\ntls-mode: tls\nbind-dn: CN=from-file,DC=example,DC=com\ntimeout: 30s\n"
+	if err := os.WriteFile(configFile, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	unknownKeyFile := filepath.Join(dir, "unknown.yaml")
+	if err := os.WriteFile(unknownKeyFile, []byte("server: dc1.example.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		want    LDAPOverrides
+		wantErr bool
+	}{
+		{
+			name: "config file",
+			args: []string{"--ldap-config", configFile},
+			want: LDAPOverrides{
+				Servers: []string{"dc1.example.com", "dc2.example.com"}, Port: 636, TLSMode: TLSModeTLS,
+				BindDN: "CN=from-file,DC=example,DC=com", Timeout: 30 * time.Second,
+			},
+		},
+		{
+			name: "environment over config file",
+			args: []string{"--ldap-config", configFile},
+			env:  map[string]string{"RANCHER_MIGRATE_LDAP_BIND_DN": "CN=from-env,DC=example,DC=com", "RANCHER_MIGRATE_LDAP_PORT": "3269"},
+			want: LDAPOverrides{
+				Servers: []string{"dc1.example.com", "dc2.example.com"}, Port: 3269, TLSMode: TLSModeTLS,
+				BindDN: "CN=from-env,DC=example,DC=com", Timeout: 30 * time.Second,
+			},
+		},
+		{
+			name: "flags over environment and config file",
+			args: []string{"--ldap-config", configFile, "--ldap-bind-dn", "CN=from-flag,DC=example,DC=com", "--ldap-servers", "dc3.example.com"},
+			env:  map[string]string{"RANCHER_MIGRATE_LDAP_BIND_DN": "CN=from-env,DC=example,DC=com"},
+			want: LDAPOverrides{
+				Servers: []string{"dc3.example.com"}, Port: 636, TLSMode: TLSModeTLS,
+				BindDN: "CN=from-flag,DC=example,DC=com", Timeout: 30 * time.Second,
+			},
+		},
+		{
+			name: "config file from the environment",
+			env:  map[string]string{"RANCHER_MIGRATE_LDAP_CONFIG": configFile, "RANCHER_MIGRATE_LDAP_PASSWORD_ENV": "AD_PASSWORD"},
+			want: LDAPOverrides{
+				Servers: []string{"dc1.example.com", "dc2.example.com"}, Port: 636, TLSMode: TLSModeTLS,
+				BindDN: "CN=from-file,DC=example,DC=com", Timeout: 30 * time.Second, PasswordEnv: "AD_PASSWORD",
+			},
+		},
+		{
+			name:    "invalid environment variable",
+			env:     map[string]string{"RANCHER_MIGRATE_LDAP_PORT": "ldaps"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown key in config file",
+			args:    []string{"--ldap-config", unknownKeyFile},
+			wantErr: true,
+		},
+		{
+			name:    "missing config file",
+			args:    []string{"--ldap-config", filepath.Join(dir, "missing.yaml")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			var (
+				overrides  LDAPOverrides
+				configPath string
+			)
+			fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			AddLDAPFlags(fs, &overrides, &configPath)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := LoadLDAPFlags(fs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadLDAPFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(overrides, tt.want) {
+				t.Errorf("LoadLDAPFlags() = %+v, want %+v", overrides, tt.want)
+			}
+		})
+	}
+}
@@ -3,8 +3,13 @@ package client
 import (
 	"context"
 	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 
+	ber "github.com/go-asn1-ber/asn1-ber"
 	ldapv3 "github.com/go-ldap/ldap/v3"
 	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/auth/providers/common/ldap"
@@ -12,8 +17,19 @@ import (
 	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
+const (
+	GlobalCatalogPort    = 3268
+	GlobalCatalogTLSPort = 3269
+)
+
+// LdapClient is an LDAP connection that can follow the referrals returned by the searches
 type LdapClient struct {
 	*ldapv3.Conn
+
+	// Config is used to connect to the servers of the referrals
+	Config *LDAPConfig
+
+	referralConns map[string]*ldapv3.Conn
 }
 
 type LDAPConfig struct {
@@ -26,9 +42,16 @@ type LDAPConfig struct {
 	ServiceAccountName     string
 	ServiceAccountPassword string
 	DefaultLoginDomain     string
+
+	// FollowReferrals enables the referral chasing of the searches
+	FollowReferrals bool
+	// MaxReferralHops is the maximum number of consecutive referrals followed by a search
+	MaxReferralHops int
 }
 
-func NewLDAPConfigFromActiveDirectory(core typedv1.CoreV1Interface, config *apiv3.ActiveDirectoryConfig) (*LDAPConfig, error) {
+// NewLDAPConfigFromActiveDirectory returns the LDAPConfig of the activedirectory authconfig.
+// The settings provided in the overrides take precedence over the ones of the authconfig.
+func NewLDAPConfigFromActiveDirectory(ctx context.Context, core typedv1.CoreV1Interface, config *apiv3.ActiveDirectoryConfig, overrides *LDAPOverrides) (*LDAPConfig, error) {
 	caPool, err := x509.SystemCertPool()
 	if err != nil {
 		return nil, err
@@ -38,19 +61,87 @@ func NewLDAPConfigFromActiveDirectory(core typedv1.CoreV1Interface, config *apiv
 		caPool.AppendCertsFromPEM([]byte(config.Certificate))
 	}
 
-	serviceAccountPassword := getServiceAccountPassword(core, config.ServiceAccountPassword)
+	ldapConfig := &LDAPConfig{
+		Servers:            config.Servers,
+		TLS:                config.TLS,
+		StartTLS:           config.StartTLS,
+		Port:               config.Port,
+		ConnectionTimeout:  config.ConnectionTimeout,
+		CAPool:             caPool,
+		ServiceAccountName: config.ServiceAccountUsername,
+		DefaultLoginDomain: config.DefaultLoginDomain,
+	}
+
+	if overrides != nil {
+		err = overrides.Apply(ctx, core, ldapConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return &LDAPConfig{
-		Servers:                config.Servers,
-		TLS:                    config.TLS,
-		StartTLS:               config.StartTLS,
-		Port:                   config.Port,
-		ConnectionTimeout:      config.ConnectionTimeout,
-		CAPool:                 caPool,
-		ServiceAccountName:     config.ServiceAccountUsername,
-		ServiceAccountPassword: serviceAccountPassword,
-		DefaultLoginDomain:     config.DefaultLoginDomain,
-	}, nil
+	// the password of the authconfig is read only if not provided by the overrides
+	if ldapConfig.ServiceAccountPassword == "" {
+		ldapConfig.ServiceAccountPassword, err = getServiceAccountPassword(ctx, core, config.ServiceAccountPassword)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ldapConfig, nil
+}
+
+// GlobalCatalog returns a copy of the LDAPConfig connecting to the Global Catalog port of the servers.
+// If no servers are provided the configured ones are used.
+func (c *LDAPConfig) GlobalCatalog(servers []string) *LDAPConfig {
+	gc := *c
+
+	if len(servers) > 0 {
+		gc.Servers = servers
+	}
+
+	gc.Port = GlobalCatalogPort
+	if gc.TLS {
+		gc.Port = GlobalCatalogTLSPort
+	}
+
+	return &gc
+}
+
+// ForURL returns a copy of the LDAPConfig connecting to the server of an LDAP URL
+// (i.e. "ldaps://dc1.child.example.com:636/DC=child,DC=example,DC=com"), and the base DN of the URL.
+func (c *LDAPConfig) ForURL(rawURL string) (*LDAPConfig, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid LDAP URL '%s': %w", rawURL, err)
+	}
+
+	config := *c
+
+	switch u.Scheme {
+	case "ldap":
+		config.TLS = false
+		config.Port = 389
+	case "ldaps":
+		config.TLS = true
+		config.StartTLS = false
+		config.Port = 636
+	default:
+		return nil, "", fmt.Errorf("invalid LDAP URL '%s': unsupported scheme '%s'", rawURL, u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return nil, "", fmt.Errorf("invalid LDAP URL '%s': missing host", rawURL)
+	}
+	config.Servers = []string{u.Hostname()}
+
+	if u.Port() != "" {
+		config.Port, err = strconv.ParseInt(u.Port(), 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid LDAP URL '%s': %w", rawURL, err)
+		}
+	}
+
+	return &config, strings.TrimPrefix(u.Path, "/"), nil
 }
 
 func NewLDAPConn(config *LDAPConfig) (*ldapv3.Conn, error) {
@@ -79,17 +170,193 @@ func NewLDAPConn(config *LDAPConfig) (*ldapv3.Conn, error) {
 	return lConn, nil
 }
 
-func getServiceAccountPassword(core typedv1.CoreV1Interface, serviceAccountPassword string) string {
+// getServiceAccountPassword returns the password of the service account. The authconfig stores a reference
+// to the Secret containing the password ("namespace:name"), or the password itself in older versions.
+func getServiceAccountPassword(ctx context.Context, core typedv1.CoreV1Interface, serviceAccountPassword string) (string, error) {
 	namespaceAndName := strings.Split(serviceAccountPassword, ":")
 	if len(namespaceAndName) < 2 {
-		return serviceAccountPassword
+		return serviceAccountPassword, nil
 	}
 
-	secretNamespace, secretName := namespaceAndName[0], namespaceAndName[1]
+	return readSecretValue(ctx, core, serviceAccountPassword)
+}
+
+// readSecretValue returns the value of a key of a Secret referenced as "namespace:name[:key]".
+// If the key is not specified the "serviceaccountpassword" key is used.
+func readSecretValue(ctx context.Context, core typedv1.CoreV1Interface, ref string) (string, error) {
+	parts := strings.Split(ref, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid Secret reference '%s': expected 'namespace:name[:key]'", ref)
+	}
 
-	sec, err := core.Secrets(secretNamespace).Get(context.Background(), secretName, v1.GetOptions{})
+	secretNamespace, secretName, key := parts[0], parts[1], "serviceaccountpassword"
+	if len(parts) == 3 {
+		key = parts[2]
+	}
+
+	sec, err := core.Secrets(secretNamespace).Get(ctx, secretName, v1.GetOptions{})
 	if err != nil {
-		return serviceAccountPassword
+		return "", fmt.Errorf("cannot read Secret '%s/%s': %w", secretNamespace, secretName, err)
 	}
-	return string(sec.Data["serviceaccountpassword"])
+
+	value, found := sec.Data[key]
+	if !found {
+		return "", fmt.Errorf("key '%s' not found in Secret '%s/%s'", key, secretNamespace, secretName)
+	}
+
+	return string(value), nil
+}
+
+// NewLdapClient returns an LdapClient connected and authenticated to the servers of the LDAPConfig
+func NewLdapClient(config *LDAPConfig) (*LdapClient, error) {
+	conn, err := NewLDAPConn(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LdapClient{
+		Conn:   conn,
+		Config: config,
+	}, nil
+}
+
+// Search performs the search request. If FollowReferrals is enabled the referrals returned by the server
+// are followed, using the same service account and TLS settings, up to MaxReferralHops.
+func (c *LdapClient) Search(searchRequest *ldapv3.SearchRequest) (*ldapv3.SearchResult, error) {
+	if c.Config == nil || !c.Config.FollowReferrals {
+		return c.Conn.Search(searchRequest)
+	}
+	return c.searchWithReferrals(c.Conn, searchRequest, 0)
+}
+
+func (c *LdapClient) searchWithReferrals(conn *ldapv3.Conn, searchRequest *ldapv3.SearchRequest, hops int) (*ldapv3.SearchResult, error) {
+	result, err := conn.Search(searchRequest)
+	if err != nil {
+		referrals := getReferrals(err)
+		if len(referrals) == 0 {
+			return nil, err
+		}
+
+		// the base DN lives in another naming context: the first reachable referral is used
+		for _, referral := range referrals {
+			var referralResult *ldapv3.SearchResult
+			referralResult, err = c.followReferral(referral, searchRequest, hops+1)
+			if err == nil {
+				return referralResult, nil
+			}
+		}
+		return nil, err
+	}
+
+	// continuation references returned by a subtree search are merged in the result.
+	// Partitions that cannot be reached are skipped.
+	var continuations []string
+	for _, referral := range result.Referrals {
+		referralResult, err := c.followReferral(referral, searchRequest, hops+1)
+		if err != nil {
+			continuations = append(continuations, referral)
+			continue
+		}
+		result.Entries = append(result.Entries, referralResult.Entries...)
+	}
+	result.Referrals = continuations
+
+	return result, nil
+}
+
+func (c *LdapClient) followReferral(referral string, searchRequest *ldapv3.SearchRequest, hops int) (*ldapv3.SearchResult, error) {
+	if hops > c.Config.MaxReferralHops {
+		return nil, ldapv3.NewError(
+			ldapv3.LDAPResultReferralLimitExceeded,
+			fmt.Errorf("referral '%s' not followed after %d hops", referral, c.Config.MaxReferralHops),
+		)
+	}
+
+	referralConfig, baseDN, err := c.Config.ForReferral(referral)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s:%d", referralConfig.Servers[0], referralConfig.Port)
+
+	conn, found := c.referralConns[key]
+	if !found {
+		conn, err = NewLDAPConn(referralConfig)
+		if err != nil {
+			return nil, fmt.Errorf("cannot follow referral '%s': %w", referral, err)
+		}
+
+		if c.referralConns == nil {
+			c.referralConns = map[string]*ldapv3.Conn{}
+		}
+		c.referralConns[key] = conn
+	}
+
+	referralRequest := *searchRequest
+	if baseDN != "" {
+		referralRequest.BaseDN = baseDN
+	}
+
+	return c.searchWithReferrals(conn, &referralRequest, hops)
+}
+
+// Close closes the connection and the connections opened to follow the referrals
+func (c *LdapClient) Close() error {
+	for _, conn := range c.referralConns {
+		conn.Close()
+	}
+	return c.Conn.Close()
+}
+
+// ForReferral returns a copy of the LDAPConfig connecting to the server of a referral URL, and the base DN of the URL.
+// The TLS settings of the LDAPConfig are kept, and the port is changed only if specified in the URL.
+func (c *LDAPConfig) ForReferral(referral string) (*LDAPConfig, string, error) {
+	u, err := url.Parse(referral)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid referral '%s': %w", referral, err)
+	}
+
+	if u.Hostname() == "" {
+		return nil, "", fmt.Errorf("invalid referral '%s': missing host", referral)
+	}
+
+	config := *c
+	config.Servers = []string{u.Hostname()}
+
+	if u.Port() != "" {
+		config.Port, err = strconv.ParseInt(u.Port(), 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid referral '%s': %w", referral, err)
+		}
+	}
+
+	return &config, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// getReferrals returns the referral URLs of an LDAP error with the Referral result code
+func getReferrals(err error) []string {
+	var ldapErr *ldapv3.Error
+	if !errors.As(err, &ldapErr) || ldapErr.ResultCode != ldapv3.LDAPResultReferral {
+		return nil
+	}
+
+	if ldapErr.Packet == nil || len(ldapErr.Packet.Children) < 2 {
+		return nil
+	}
+
+	var referrals []string
+	for _, child := range ldapErr.Packet.Children[1].Children {
+		// the referral field of the LDAPResult is tagged as [3]
+		if child.ClassType != ber.ClassContext || child.TagType != ber.TypeConstructed || child.Tag != 3 {
+			continue
+		}
+
+		for _, ref := range child.Children {
+			if referral, ok := ref.Value.(string); ok {
+				referrals = append(referrals, referral)
+			}
+		}
+	}
+
+	return referrals
 }
@@ -0,0 +1,360 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	ldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// newResultPacket returns the decoded LDAP message of a SearchResultDone with the result code,
+// and the referral field with the URLs if any
+func newResultPacket(messageID int64, resultCode uint16, referrals ...string) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapv3.ApplicationSearchResultDone, nil, "Search Result Done")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+
+	if len(referrals) > 0 {
+		referral := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, nil, "referral")
+		for _, url := range referrals {
+			referral.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, url, "URI"))
+		}
+		result.AppendChild(referral)
+	}
+	packet.AppendChild(result)
+
+	return decodePacket(packet)
+}
+
+// decodePacket returns the packet as read from the connection
+func decodePacket(packet *ber.Packet) *ber.Packet {
+	decoded, err := ber.DecodePacketErr(packet.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	return decoded
+}
+
+func TestGetReferrals(t *testing.T) {
+	const (
+		child  = "ldap://child.example.com/DC=child,DC=example,DC=com"
+		child2 = "ldaps://dc2.child.example.com:636/DC=child,DC=example,DC=com"
+	)
+
+	noReferralField := newResultPacket(1, ldapv3.LDAPResultReferral)
+
+	malformed := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	malformed.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 1, "MessageID"))
+
+	wrongTag := newResultPacket(1, ldapv3.LDAPResultReferral)
+	wrongTag.Children[1].AppendChild(decodePacket(
+		ber.NewString(ber.ClassContext, ber.TypePrimitive, 7, child, "not a referral"),
+	))
+
+	tests := []struct {
+		name string
+		err  error
+		want []string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "not an LDAP error",
+			err:  errors.New("connection refused"),
+		},
+		{
+			name: "not a referral",
+			err:  ldapv3.GetLDAPError(newResultPacket(1, ldapv3.LDAPResultNoSuchObject, child)),
+		},
+		{
+			name: "no packet",
+			err:  ldapv3.NewError(ldapv3.LDAPResultReferral, errors.New("referral")),
+		},
+		{
+			name: "no referral field",
+			err:  &ldapv3.Error{ResultCode: ldapv3.LDAPResultReferral, Packet: noReferralField},
+		},
+		{
+			name: "malformed packet",
+			err:  &ldapv3.Error{ResultCode: ldapv3.LDAPResultReferral, Packet: decodePacket(malformed)},
+		},
+		{
+			name: "other context field",
+			err:  &ldapv3.Error{ResultCode: ldapv3.LDAPResultReferral, Packet: wrongTag},
+		},
+		{
+			name: "single URL",
+			err:  ldapv3.GetLDAPError(newResultPacket(1, ldapv3.LDAPResultReferral, child)),
+			want: []string{child},
+		},
+		{
+			name: "multiple URLs",
+			err:  ldapv3.GetLDAPError(newResultPacket(1, ldapv3.LDAPResultReferral, child, child2)),
+			want: []string{child, child2},
+		},
+		{
+			name: "wrapped error",
+			err:  errors.Join(errors.New("search failed"), ldapv3.GetLDAPError(newResultPacket(1, ldapv3.LDAPResultReferral, child))),
+			want: []string{child},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getReferrals(tt.err); !slices.Equal(got, tt.want) {
+				t.Errorf("getReferrals() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForReferral(t *testing.T) {
+	config := &LDAPConfig{Servers: []string{"dc1.example.com"}, TLS: true, Port: 636}
+
+	tests := []struct {
+		name       string
+		referral   string
+		wantServer string
+		wantPort   int64
+		wantBaseDN string
+		wantErr    bool
+	}{
+		{
+			name:       "host and base DN",
+			referral:   "ldap://child.example.com/DC=child,DC=example,DC=com",
+			wantServer: "child.example.com",
+			wantPort:   636,
+			wantBaseDN: "DC=child,DC=example,DC=com",
+		},
+		{
+			name:       "port",
+			referral:   "ldaps://child.example.com:3269/",
+			wantServer: "child.example.com",
+			wantPort:   3269,
+		},
+		{name: "missing host", referral: "ldap:///DC=example,DC=com", wantErr: true},
+		{name: "invalid port", referral: "ldap://child.example.com:ldap/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, baseDN, err := config.ForReferral(tt.referral)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ForReferral() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !slices.Equal(got.Servers, []string{tt.wantServer}) || got.Port != tt.wantPort || !got.TLS {
+				t.Errorf("ForReferral() = %v:%d (TLS %v), want %s:%d (TLS true)", got.Servers, got.Port, got.TLS, tt.wantServer, tt.wantPort)
+			}
+			if baseDN != tt.wantBaseDN {
+				t.Errorf("ForReferral() base DN = %q, want %q", baseDN, tt.wantBaseDN)
+			}
+		})
+	}
+}
+
+// newReferralServer returns a connection to a fake LDAP server answering every search
+// with the responses, built with the message ID of the request
+func newReferralServer(t *testing.T, responses ...func(messageID int64) *ber.Packet) *ldapv3.Conn {
+	t.Helper()
+
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+
+		for {
+			request, err := ber.ReadPacket(server)
+			if err != nil {
+				return
+			}
+			if len(request.Children) < 2 || request.Children[1].Tag != ldapv3.ApplicationSearchRequest {
+				continue
+			}
+
+			messageID := request.Children[0].Value.(int64)
+			for _, response := range responses {
+				if _, err := server.Write(response(messageID).Bytes()); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	conn := ldapv3.NewConn(client, false)
+	conn.Start()
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func TestSearchReferralHops(t *testing.T) {
+	const child = "ldap://child.example.com/DC=child,DC=example,DC=com"
+
+	searchRequest := ldapv3.NewSearchRequest(
+		"DC=example,DC=com", ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases,
+		0, 0, false, "(objectClass=user)", []string{"objectGUID"}, nil,
+	)
+
+	t.Run("base DN referral not followed after the hops", func(t *testing.T) {
+		conn := newReferralServer(t, func(messageID int64) *ber.Packet {
+			return newResultPacket(messageID, ldapv3.LDAPResultReferral, child)
+		})
+		c := &LdapClient{Conn: conn, Config: &LDAPConfig{FollowReferrals: true, MaxReferralHops: 0}}
+
+		_, err := c.Search(searchRequest)
+		if !ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultReferralLimitExceeded) {
+			t.Errorf("Search() error = %v, want referral limit exceeded", err)
+		}
+	})
+
+	t.Run("continuation references not followed after the hops", func(t *testing.T) {
+		conn := newReferralServer(t,
+			func(messageID int64) *ber.Packet {
+				packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+				packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+				reference := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapv3.ApplicationSearchResultReference, nil, "Search Result Reference")
+				reference.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, child, "URI"))
+				packet.AppendChild(reference)
+				return packet
+			},
+			func(messageID int64) *ber.Packet {
+				return newResultPacket(messageID, ldapv3.LDAPResultSuccess)
+			},
+		)
+		c := &LdapClient{Conn: conn, Config: &LDAPConfig{FollowReferrals: true, MaxReferralHops: 0}}
+
+		result, err := c.Search(searchRequest)
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		if !slices.Equal(result.Referrals, []string{child}) {
+			t.Errorf("Search() referrals = %v, want the unreachable %v", result.Referrals, []string{child})
+		}
+	})
+
+	t.Run("referrals not followed when disabled", func(t *testing.T) {
+		conn := newReferralServer(t, func(messageID int64) *ber.Packet {
+			return newResultPacket(messageID, ldapv3.LDAPResultReferral, child)
+		})
+		c := &LdapClient{Conn: conn, Config: &LDAPConfig{MaxReferralHops: 5}}
+
+		_, err := c.Search(searchRequest)
+		if !ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultReferral) {
+			t.Errorf("Search() error = %v, want the referral", err)
+		}
+		if got := getReferrals(err); !slices.Equal(got, []string{child}) {
+			t.Errorf("getReferrals() = %v, want %v", got, []string{child})
+		}
+	})
+}
+
+func TestGlobalCatalog(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      LDAPConfig
+		servers     []string
+		wantServers []string
+		wantPort    int64
+	}{
+		{
+			name:        "configured servers",
+			config:      LDAPConfig{Servers: []string{"dc1.example.com"}, Port: 389},
+			wantServers: []string{"dc1.example.com"},
+			wantPort:    GlobalCatalogPort,
+		},
+		{
+			name:        "configured servers with TLS",
+			config:      LDAPConfig{Servers: []string{"dc1.example.com"}, TLS: true, Port: 636},
+			wantServers: []string{"dc1.example.com"},
+			wantPort:    GlobalCatalogTLSPort,
+		},
+		{
+			name:        "provided servers",
+			config:      LDAPConfig{Servers: []string{"dc1.example.com"}, Port: 389},
+			servers:     []string{"gc1.example.com", "gc2.example.com"},
+			wantServers: []string{"gc1.example.com", "gc2.example.com"},
+			wantPort:    GlobalCatalogPort,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.config.GlobalCatalog(tt.servers)
+
+			if !slices.Equal(got.Servers, tt.wantServers) || got.Port != tt.wantPort {
+				t.Errorf("GlobalCatalog() = %v:%d, want %v:%d", got.Servers, got.Port, tt.wantServers, tt.wantPort)
+			}
+			if !slices.Equal(tt.config.Servers, []string{"dc1.example.com"}) {
+				t.Errorf("GlobalCatalog() changed the servers of the config to %v", tt.config.Servers)
+			}
+		})
+	}
+}
+
+func TestForURL(t *testing.T) {
+	config := &LDAPConfig{Servers: []string{"dc1.example.com"}, StartTLS: true, Port: 389}
+
+	tests := []struct {
+		name         string
+		url          string
+		wantServer   string
+		wantTLS      bool
+		wantStartTLS bool
+		wantPort     int64
+		wantBaseDN   string
+		wantErr      bool
+	}{
+		{
+			name:         "ldap",
+			url:          "ldap://dc1.child.example.com/DC=child,DC=example,DC=com",
+			wantServer:   "dc1.child.example.com",
+			wantStartTLS: true,
+			wantPort:     389,
+			wantBaseDN:   "DC=child,DC=example,DC=com",
+		},
+		{
+			name:       "ldaps with port",
+			url:        "ldaps://dc1.child.example.com:3269/DC=child,DC=example,DC=com",
+			wantServer: "dc1.child.example.com",
+			wantTLS:    true,
+			wantPort:   3269,
+			wantBaseDN: "DC=child,DC=example,DC=com",
+		},
+		{name: "unsupported scheme", url: "https://dc1.child.example.com/", wantErr: true},
+		{name: "missing host", url: "ldap:///DC=child,DC=example,DC=com", wantErr: true},
+		{name: "invalid port", url: "ldap://dc1.child.example.com:ldap/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, baseDN, err := config.ForURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ForURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !slices.Equal(got.Servers, []string{tt.wantServer}) || got.Port != tt.wantPort {
+				t.Errorf("ForURL() = %v:%d, want %s:%d", got.Servers, got.Port, tt.wantServer, tt.wantPort)
+			}
+			if got.TLS != tt.wantTLS || got.StartTLS != tt.wantStartTLS {
+				t.Errorf("ForURL() TLS = %v, StartTLS = %v, want %v, %v", got.TLS, got.StartTLS, tt.wantTLS, tt.wantStartTLS)
+			}
+			if baseDN != tt.wantBaseDN {
+				t.Errorf("ForURL() base DN = %q, want %q", baseDN, tt.wantBaseDN)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// LockName is the name of the Lease held by the commands updating the resources
+	LockName = "rancher-migrate-lock"
+	// LockNamespace is the namespace of the Lease
+	LockNamespace = "cattle-system"
+
+	// lockOperationAnnotation is the operation of the holder of the Lease
+	lockOperationAnnotation = "rancher-migrate/operation"
+)
+
+var (
+	// lockDuration is how long the Lease is valid without being renewed
+	lockDuration = 60 * time.Second
+	// lockRenewInterval is how often the Lease is renewed
+	lockRenewInterval = 15 * time.Second
+)
+
+// ErrLockLost is the cause of the cancellation of the context of a Lock that could not be renewed
+var ErrLockLost = errors.New("lock lost")
+
+// Lock is the state of the Lease preventing concurrent updates of the resources
+type Lock struct {
+	// Holder identifies the process holding the Lock (i.e. "user@host (pid 123)")
+	Holder string
+	// Operation is the operation of the holder (i.e. "v1.10.0 migrate")
+	Operation string
+	Acquired  time.Time
+	Renewed   time.Time
+	Duration  time.Duration
+}
+
+// Expired returns true if the Lock was not renewed in time, i.e. because its holder was killed
+func (l *Lock) Expired() bool {
+	return time.Since(l.Renewed) > l.Duration
+}
+
+func (l *Lock) String() string {
+	return fmt.Sprintf(
+		"held by %s (%s) since %s, renewed %s ago",
+		l.Holder, l.Operation, l.Acquired.Format(time.RFC3339), time.Since(l.Renewed).Round(time.Second),
+	)
+}
+
+// GetLock returns the current Lock, or nil if it is not held
+func (c *RancherClient) GetLock(ctx context.Context) (*Lock, error) {
+	lease, err := c.Kube.CoordinationV1().Leases(LockNamespace).Get(ctx, LockName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting lock: %w", err)
+	}
+
+	return leaseLock(lease), nil
+}
+
+// AcquireLock acquires the Lease for the holder, failing if it is held by another process that is still renewing it,
+// unless forced. The Lease is renewed until released: the returned context is canceled with ErrLockLost
+// if it cannot be renewed, or if it is acquired by another process.
+func (c *RancherClient) AcquireLock(ctx context.Context, holder, operation string, force bool) (context.Context, func(), error) {
+	leases := c.Kube.CoordinationV1().Leases(LockNamespace)
+	now := metav1.NowMicro()
+
+	lease, err := leases.Get(ctx, LockName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, nil, fmt.Errorf("getting lock: %w", err)
+	}
+
+	found := err == nil
+
+	if !found {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      LockName,
+				Namespace: LockNamespace,
+			},
+		}
+	} else if current := leaseLock(lease); current != nil && current.Holder != holder {
+		if !current.Expired() && !force {
+			return nil, nil, fmt.Errorf(
+				"another command is updating the resources, lock %s: "+
+					"use --force-unlock only if it is not running anymore", current,
+			)
+		}
+
+		slog.Warn("lock taken over", "previousHolder", current.Holder, "operation", current.Operation, "expired", current.Expired())
+	}
+
+	transitions := int32(0)
+	if lease.Spec.LeaseTransitions != nil {
+		transitions = *lease.Spec.LeaseTransitions + 1
+	}
+	durationSeconds := int32(lockDuration.Seconds())
+
+	lease.Annotations = map[string]string{lockOperationAnnotation: operation}
+	lease.Spec = coordinationv1.LeaseSpec{
+		HolderIdentity:       &holder,
+		LeaseDurationSeconds: &durationSeconds,
+		AcquireTime:          &now,
+		RenewTime:            &now,
+		LeaseTransitions:     &transitions,
+	}
+
+	// the resourceVersion of the updated Lease, or the create, fail if another process acquired it meanwhile
+	if !found {
+		lease, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+	} else {
+		lease, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		if apierrors.IsAlreadyExists(err) || apierrors.IsConflict(err) {
+			return nil, nil, errors.New("the lock was acquired by another command meanwhile")
+		}
+		return nil, nil, fmt.Errorf("cannot acquire lock: %w", err)
+	}
+	slog.Info("lock acquired", "holder", holder, "operation", operation)
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		c.renewLock(lockCtx, lease, stop, cancel)
+	}()
+
+	release := func() {
+		close(stop)
+		<-done
+		cancel(nil)
+
+		c.releaseLock(context.WithoutCancel(ctx), holder)
+	}
+
+	return lockCtx, release, nil
+}
+
+// renewLock renews the Lease until stopped, canceling the context if it is lost. The Lease is renewed
+// also after the cancellation of the context, until the interrupted update is completed and the Lease released.
+func (c *RancherClient) renewLock(ctx context.Context, lease *coordinationv1.Lease, stop <-chan struct{}, cancel context.CancelCauseFunc) {
+	ctx = context.WithoutCancel(ctx)
+	leases := c.Kube.CoordinationV1().Leases(LockNamespace)
+	holder := *lease.Spec.HolderIdentity
+	renewed := lease.Spec.RenewTime.Time
+
+	ticker := time.NewTicker(lockRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current, err := leases.Get(ctx, LockName, metav1.GetOptions{})
+		if err == nil {
+			if lock := leaseLock(current); lock == nil || lock.Holder != holder {
+				slog.Error("lock released or acquired by another command")
+				cancel(fmt.Errorf("%w: released or acquired by another command", ErrLockLost))
+				return
+			}
+
+			now := metav1.NowMicro()
+			current.Spec.RenewTime = &now
+			_, err = leases.Update(ctx, current, metav1.UpdateOptions{})
+			if err == nil {
+				renewed = now.Time
+				slog.Debug("lock renewed", "holder", holder)
+				continue
+			}
+		}
+
+		slog.Warn("cannot renew lock", "holder", holder, "error", err)
+		if time.Since(renewed) > lockDuration {
+			cancel(fmt.Errorf("%w: %w", ErrLockLost, err))
+			return
+		}
+	}
+}
+
+// releaseLock deletes the Lease, if it is still held by the holder
+func (c *RancherClient) releaseLock(ctx context.Context, holder string) {
+	leases := c.Kube.CoordinationV1().Leases(LockNamespace)
+
+	lease, err := leases.Get(ctx, LockName, metav1.GetOptions{})
+	if err != nil || lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != holder {
+		return
+	}
+
+	err = leases.Delete(ctx, LockName, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+	if err != nil {
+		slog.Warn("cannot release lock", "holder", holder, "error", err)
+		return
+	}
+	slog.Info("lock released", "holder", holder)
+}
+
+// leaseLock returns the Lock of the Lease, or nil if it has no holder
+func leaseLock(lease *coordinationv1.Lease) *Lock {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" {
+		return nil
+	}
+
+	lock := &Lock{
+		Holder:    *lease.Spec.HolderIdentity,
+		Operation: lease.Annotations[lockOperationAnnotation],
+		Duration:  lockDuration,
+	}
+	if lease.Spec.LeaseDurationSeconds != nil {
+		lock.Duration = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	}
+	if lease.Spec.AcquireTime != nil {
+		lock.Acquired = lease.Spec.AcquireTime.Time
+	}
+	if lease.Spec.RenewTime != nil {
+		lock.Renewed = lease.Spec.RenewTime.Time
+	}
+
+	return lock
+}
@@ -0,0 +1,205 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testHolder = "john@host (pid 123)"
+
+// newLease returns the Lease of a Lock held by the holder, last renewed at the time
+func newLease(holder string, renewed time.Time) *coordinationv1.Lease {
+	durationSeconds := int32(60)
+	transitions := int32(2)
+	renewTime := metav1.NewMicroTime(renewed)
+
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        LockName,
+			Namespace:   LockNamespace,
+			Annotations: map[string]string{lockOperationAnnotation: "v1.10.0 migrate"},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &durationSeconds,
+			AcquireTime:          &renewTime,
+			RenewTime:            &renewTime,
+			LeaseTransitions:     &transitions,
+		},
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	const other = "jane@other-host (pid 456)"
+
+	tests := []struct {
+		name            string
+		lease           *coordinationv1.Lease
+		force           bool
+		wantErr         bool
+		wantTransitions int32
+	}{
+		{
+			name:            "not held",
+			wantTransitions: 0,
+		},
+		{
+			name:            "expired",
+			lease:           newLease(other, time.Now().Add(-2*time.Minute)),
+			wantTransitions: 3,
+		},
+		{
+			name:    "held by a live holder",
+			lease:   newLease(other, time.Now()),
+			wantErr: true,
+		},
+		{
+			name:            "held by a live holder with --force-unlock",
+			lease:           newLease(other, time.Now()),
+			force:           true,
+			wantTransitions: 3,
+		},
+		{
+			name:            "held by the same holder",
+			lease:           newLease(testHolder, time.Now()),
+			wantTransitions: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objects []runtime.Object
+			if tt.lease != nil {
+				objects = append(objects, tt.lease)
+			}
+			c := &RancherClient{Kube: fake.NewSimpleClientset(objects...)}
+
+			ctx, release, err := c.AcquireLock(context.Background(), testHolder, "v1.10.0 rollback", tt.force)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AcquireLock() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				lock, err := c.GetLock(context.Background())
+				if err != nil || lock == nil || lock.Holder != other {
+					t.Errorf("GetLock() = %v, %v, want the lock of the live holder", lock, err)
+				}
+				return
+			}
+
+			lease, err := c.Kube.CoordinationV1().Leases(LockNamespace).Get(ctx, LockName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lock := leaseLock(lease); lock == nil || lock.Holder != testHolder || lock.Operation != "v1.10.0 rollback" || lock.Expired() {
+				t.Errorf("lock = %v, want held by %s", lock, testHolder)
+			}
+			if got := *lease.Spec.LeaseTransitions; got != tt.wantTransitions {
+				t.Errorf("lease transitions = %d, want %d", got, tt.wantTransitions)
+			}
+
+			release()
+
+			if ctx.Err() == nil {
+				t.Error("context not canceled by the release")
+			}
+			if lock, err := c.GetLock(context.Background()); err != nil || lock != nil {
+				t.Errorf("GetLock() after release = %v, %v, want not held", lock, err)
+			}
+		})
+	}
+}
+
+func TestLockRenewal(t *testing.T) {
+	duration, interval := lockDuration, lockRenewInterval
+	lockDuration, lockRenewInterval = 100*time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() { lockDuration, lockRenewInterval = duration, interval })
+
+	waitLost := func(t *testing.T, ctx context.Context) {
+		t.Helper()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("context not canceled")
+		}
+		if cause := context.Cause(ctx); !errors.Is(cause, ErrLockLost) {
+			t.Errorf("context canceled by %v, want %v", cause, ErrLockLost)
+		}
+	}
+
+	t.Run("renewed while held", func(t *testing.T) {
+		c := &RancherClient{Kube: fake.NewSimpleClientset()}
+
+		ctx, release, err := c.AcquireLock(context.Background(), testHolder, "v1.10.0 migrate", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+
+		time.Sleep(3 * lockDuration)
+
+		if ctx.Err() != nil {
+			t.Fatalf("context canceled by %v", context.Cause(ctx))
+		}
+		lock, err := c.GetLock(ctx)
+		if err != nil || lock == nil || time.Since(lock.Renewed) > lockDuration {
+			t.Errorf("GetLock() = %v, %v, want renewed", lock, err)
+		}
+	})
+
+	t.Run("renewal failure", func(t *testing.T) {
+		// the reactors cannot be added while the Lease is renewed
+		var unreachable atomic.Bool
+		kube := fake.NewSimpleClientset()
+		kube.PrependReactor("update", "leases", func(k8stesting.Action) (bool, runtime.Object, error) {
+			if unreachable.Load() {
+				return true, nil, errors.New("connection refused")
+			}
+			return false, nil, nil
+		})
+		c := &RancherClient{Kube: kube}
+
+		ctx, release, err := c.AcquireLock(context.Background(), testHolder, "v1.10.0 migrate", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+
+		unreachable.Store(true)
+		waitLost(t, ctx)
+	})
+
+	t.Run("acquired by another command", func(t *testing.T) {
+		c := &RancherClient{Kube: fake.NewSimpleClientset()}
+
+		ctx, release, err := c.AcquireLock(context.Background(), testHolder, "v1.10.0 migrate", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, releaseOther, err := c.AcquireLock(context.Background(), "jane@other-host (pid 456)", "v1.10.0 migrate", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer releaseOther()
+
+		waitLost(t, ctx)
+		release()
+
+		// the release does not delete the Lease of the new holder
+		lock, err := c.GetLock(context.Background())
+		if err != nil || lock == nil || lock.Holder != "jane@other-host (pid 456)" {
+			t.Errorf("GetLock() = %v, %v, want held by the other command", lock, err)
+		}
+	})
+}
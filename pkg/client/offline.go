@@ -0,0 +1,238 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/rest"
+)
+
+// offlineHost is the fake host of the offline RancherClient
+const offlineHost = "http://offline"
+
+// NewOfflineRancherClient returns a read-only RancherClient serving the resources exported in a directory
+// (i.e. with "kubectl get users.management.cattle.io -o yaml > users.yaml"), without connecting to any cluster.
+// Every request that is not a get or a list fails.
+func NewOfflineRancherClient(dir string) (*RancherClient, error) {
+	transport, err := newOfflineTransport(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRancherClient(&rest.Config{
+		Host:      offlineHost,
+		Transport: transport,
+	})
+}
+
+// offlineObject is an exported resource
+type offlineObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+
+	raw json.RawMessage
+}
+
+// offlineTransport is an http.RoundTripper serving the exported resources as the Kubernetes API would
+type offlineTransport struct {
+	// resources are the exported objects indexed by their plural lowercase resource name (i.e. "users")
+	resources map[string][]offlineObject
+	// kinds are the kinds of the resources, used for the kind of the lists
+	kinds map[string]string
+}
+
+func newOfflineTransport(dir string) (*offlineTransport, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read exports directory: %w", err)
+	}
+
+	t := &offlineTransport{
+		resources: map[string][]offlineObject{},
+		kinds:     map[string]string{},
+	}
+
+	scheme := runtime.NewScheme()
+	err = apiv3.AddToScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+	for gvk := range scheme.AllKnownTypes() {
+		t.kinds[resourceName(gvk.Kind)] = gvk.Kind
+	}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		err := t.load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// load adds the objects of a file, that can contain multiple documents and Lists
+func (t *offlineTransport) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open export: %w", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		doc := struct {
+			offlineObject
+			Items []json.RawMessage `json:"items"`
+		}{}
+
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot decode export '%s': %w", path, err)
+		}
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+
+		err = json.Unmarshal(raw, &doc)
+		if err != nil {
+			return fmt.Errorf("cannot decode export '%s': %w", path, err)
+		}
+
+		if !strings.HasSuffix(doc.Kind, "List") {
+			t.add(doc.offlineObject, raw)
+			continue
+		}
+
+		for _, item := range doc.Items {
+			obj := offlineObject{}
+			err = json.Unmarshal(item, &obj)
+			if err != nil {
+				return fmt.Errorf("cannot decode item of export '%s': %w", path, err)
+			}
+			t.add(obj, item)
+		}
+	}
+}
+
+func (t *offlineTransport) add(obj offlineObject, raw json.RawMessage) {
+	if obj.Kind == "" {
+		return
+	}
+	obj.raw = raw
+
+	resource := resourceName(obj.Kind)
+	t.resources[resource] = append(t.resources[resource], obj)
+	t.kinds[resource] = obj.Kind
+}
+
+// resourceName returns the plural lowercase resource name of a kind
+func resourceName(kind string) string {
+	return strings.ToLower(kind) + "s"
+}
+
+// RoundTrip serves the get and list requests of the paths
+// "/apis/<group>/<version>/[namespaces/<namespace>/]<resource>[/<name>]" (or "/api/v1/..." for the core group)
+func (t *offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return statusResponse(req, http.StatusMethodNotAllowed, "MethodNotAllowed",
+			fmt.Sprintf("offline mode: %s %s is not supported", req.Method, req.URL.Path)), nil
+	}
+
+	var apiVersion string
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) > 2 && parts[0] == "api":
+		apiVersion, parts = parts[1], parts[2:]
+	case len(parts) > 3 && parts[0] == "apis":
+		apiVersion, parts = parts[1]+"/"+parts[2], parts[3:]
+	default:
+		return statusResponse(req, http.StatusNotFound, "NotFound", "offline mode: invalid path "+req.URL.Path), nil
+	}
+
+	var namespace string
+	if len(parts) > 2 && parts[0] == "namespaces" {
+		namespace, parts = parts[1], parts[2:]
+	}
+
+	if len(parts) == 0 || len(parts) > 2 {
+		return statusResponse(req, http.StatusNotFound, "NotFound", "offline mode: invalid path "+req.URL.Path), nil
+	}
+
+	resource := parts[0]
+	var items []json.RawMessage
+	for _, obj := range t.resources[resource] {
+		if namespace != "" && obj.Metadata.Namespace != namespace {
+			continue
+		}
+
+		if len(parts) == 2 {
+			if obj.Metadata.Name == parts[1] {
+				return jsonResponse(req, http.StatusOK, obj.raw), nil
+			}
+			continue
+		}
+
+		items = append(items, obj.raw)
+	}
+
+	if len(parts) == 2 {
+		return statusResponse(req, http.StatusNotFound, "NotFound",
+			fmt.Sprintf("offline mode: %s '%s' not found", resource, parts[1])), nil
+	}
+
+	list, err := json.Marshal(map[string]any{
+		"apiVersion": apiVersion,
+		"kind":       t.kinds[resource] + "List",
+		"items":      append([]json.RawMessage{}, items...),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jsonResponse(req, http.StatusOK, list), nil
+}
+
+func jsonResponse(req *http.Request, code int, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func statusResponse(req *http.Request, code int, reason, message string) *http.Response {
+	body, _ := json.Marshal(map[string]any{
+		"kind":       "Status",
+		"apiVersion": "v1",
+		"status":     "Failure",
+		"reason":     reason,
+		"message":    message,
+		"code":       code,
+	})
+	return jsonResponse(req, code, body)
+}
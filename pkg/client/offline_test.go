@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const usersExport = `apiVersion: v1
+kind: List
+items:
+- apiVersion: management.cattle.io/v3
+  kind: User
+  metadata:
+    name: u-john
+  principalIds:
+  - activedirectory_user://CN=john,DC=example,DC=com
+- apiVersion: management.cattle.io/v3
+  kind: User
+  metadata:
+    name: u-jane
+`
+
+const prtbsExport = `apiVersion: management.cattle.io/v3
+kind: ProjectRoleTemplateBinding
+metadata:
+  name: prtb-1
+  namespace: p-1
+userPrincipalName: activedirectory_user://CN=john,DC=example,DC=com
+---
+apiVersion: management.cattle.io/v3
+kind: ProjectRoleTemplateBinding
+metadata:
+  name: prtb-2
+  namespace: p-2
+`
+
+const secretExport = `{
+  "apiVersion": "v1",
+  "kind": "Secret",
+  "metadata": {"name": "password", "namespace": "cattle-global-data"},
+  "stringData": {"password": "secret"}
+}`
+
+func newTestOfflineClient(t *testing.T) *RancherClient {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"users.yaml":  usersExport,
+		"prtbs.yml":   prtbsExport,
+		"secret.json": secretExport,
+		"README.md":   "not an export",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := NewOfflineRancherClient(dir)
+	if err != nil {
+		t.Fatalf("NewOfflineRancherClient() error = %v", err)
+	}
+	return c
+}
+
+func TestOfflineRancherClient(t *testing.T) {
+	c := newTestOfflineClient(t)
+	ctx := context.Background()
+
+	users := &apiv3.UserList{}
+	err := c.Rancher.Get().Resource("users").Do(ctx).Into(users)
+	if err != nil {
+		t.Fatalf("list users error = %v", err)
+	}
+	if len(users.Items) != 2 || users.Items[0].Name != "u-john" || len(users.Items[0].PrincipalIDs) != 1 {
+		t.Errorf("list users = %+v, want u-john and u-jane", users.Items)
+	}
+
+	prtbs := &apiv3.ProjectRoleTemplateBindingList{}
+	err = c.Rancher.Get().Resource("projectroletemplatebindings").Namespace("p-2").Do(ctx).Into(prtbs)
+	if err != nil {
+		t.Fatalf("list PRTBs error = %v", err)
+	}
+	if len(prtbs.Items) != 1 || prtbs.Items[0].Name != "prtb-2" {
+		t.Errorf("list PRTBs in p-2 = %+v, want prtb-2", prtbs.Items)
+	}
+
+	// the kind of the list of the resources not exported is known from the scheme
+	crtbs := &apiv3.ClusterRoleTemplateBindingList{}
+	err = c.Rancher.Get().Resource("clusterroletemplatebindings").Do(ctx).Into(crtbs)
+	if err != nil || len(crtbs.Items) != 0 {
+		t.Errorf("list CRTBs = %+v, %v, want empty", crtbs.Items, err)
+	}
+
+	user := &apiv3.User{}
+	err = c.Rancher.Get().Resource("users").Name("u-jane").Do(ctx).Into(user)
+	if err != nil || user.Name != "u-jane" {
+		t.Errorf("get user = %s, %v, want u-jane", user.Name, err)
+	}
+
+	err = c.Rancher.Get().Resource("users").Name("u-missing").Do(ctx).Into(user)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("get missing user error = %v, want NotFound", err)
+	}
+
+	secret, err := c.Kube.CoreV1().Secrets("cattle-global-data").Get(ctx, "password", metav1.GetOptions{})
+	if err != nil || secret.StringData["password"] != "secret" {
+		t.Errorf("get secret = %v, %v, want the exported secret", secret, err)
+	}
+
+	err = c.Rancher.Put().Resource("users").Name("u-jane").Body(user).Do(ctx).Error()
+	if !apierrors.IsMethodNotSupported(err) {
+		t.Errorf("update user error = %v, want MethodNotAllowed", err)
+	}
+}
+
+func TestOfflineRancherClientInvalidExport(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "users.yaml"), []byte("kind: [User"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewOfflineRancherClient(dir)
+	if err == nil {
+		t.Error("NewOfflineRancherClient() error = nil, want the decoding error")
+	}
+
+	_, err = NewOfflineRancherClient(filepath.Join(dir, "missing"))
+	if err == nil {
+		t.Error("NewOfflineRancherClient() error = nil, want the missing directory error")
+	}
+}
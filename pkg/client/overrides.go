@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	TLSModeNone     = "none"
+	TLSModeTLS      = "tls"
+	TLSModeStartTLS = "starttls"
+)
+
+// LDAPOverrides are the connection settings that override the ones of the activedirectory authconfig,
+// i.e. to connect with a dedicated read-only service account instead of the Rancher one.
+type LDAPOverrides struct {
+	Servers []string
+	Port    int64
+	// TLSMode is one of "none", "tls" or "starttls"
+	TLSMode string
+	// CAFile is a PEM bundle of CA certificates trusted for the TLS connections
+	CAFile string
+	// BindDN is used as is to bind, without the DefaultLoginDomain
+	BindDN string
+	// Timeout is the timeout of the connection and of every LDAP operation
+	Timeout time.Duration
+
+	// PasswordFile is the path of a file containing the bind password
+	PasswordFile string
+	// PasswordEnv is the name of an environment variable containing the bind password
+	PasswordEnv string
+	// PasswordSecret is a reference to a Secret containing the bind password ("namespace:name[:key]")
+	PasswordSecret string
+}
+
+// Apply overrides the settings of the LDAPConfig
+func (o *LDAPOverrides) Apply(ctx context.Context, core typedv1.CoreV1Interface, config *LDAPConfig) error {
+	if len(o.Servers) > 0 {
+		config.Servers = o.Servers
+	}
+
+	if o.Port != 0 {
+		config.Port = o.Port
+	}
+
+	switch o.TLSMode {
+	case "":
+	case TLSModeNone:
+		config.TLS, config.StartTLS = false, false
+	case TLSModeTLS:
+		config.TLS, config.StartTLS = true, false
+	case TLSModeStartTLS:
+		config.TLS, config.StartTLS = false, true
+	default:
+		return fmt.Errorf(
+			"invalid TLS mode '%s': must be one of %s, %s or %s",
+			o.TLSMode, TLSModeNone, TLSModeTLS, TLSModeStartTLS,
+		)
+	}
+
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return fmt.Errorf("cannot read CA file: %w", err)
+		}
+
+		if !config.CAPool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no valid certificates found in CA file '%s'", o.CAFile)
+		}
+	}
+
+	if o.BindDN != "" {
+		config.ServiceAccountName = o.BindDN
+		config.DefaultLoginDomain = ""
+	}
+
+	if o.Timeout > 0 {
+		config.ConnectionTimeout = o.Timeout.Milliseconds()
+	}
+
+	password, err := o.password(ctx, core)
+	if err != nil {
+		return err
+	}
+	if password != "" {
+		config.ServiceAccountPassword = password
+	}
+
+	return nil
+}
+
+// password returns the bind password from the configured source, or an empty string if none is set
+func (o *LDAPOverrides) password(ctx context.Context, core typedv1.CoreV1Interface) (string, error) {
+	var sources []string
+	for _, source := range []string{o.PasswordFile, o.PasswordEnv, o.PasswordSecret} {
+		if source != "" {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) > 1 {
+		return "", fmt.Errorf("only one password source can be used, found %d", len(sources))
+	}
+
+	switch {
+	case o.PasswordFile != "":
+		b, err := os.ReadFile(o.PasswordFile)
+		if err != nil {
+			return "", fmt.Errorf("cannot read password file: %w", err)
+		}
+		password := strings.TrimRight(string(b), "\r\n")
+		if password == "" {
+			return "", fmt.Errorf("password file '%s' is empty", o.PasswordFile)
+		}
+		return password, nil
+
+	case o.PasswordEnv != "":
+		password, found := os.LookupEnv(o.PasswordEnv)
+		if !found || password == "" {
+			return "", fmt.Errorf("environment variable '%s' is not set", o.PasswordEnv)
+		}
+		return password, nil
+
+	case o.PasswordSecret != "":
+		password, err := readSecretValue(ctx, core, o.PasswordSecret)
+		if err != nil {
+			return "", err
+		}
+		if password == "" {
+			return "", fmt.Errorf("password in Secret '%s' is empty", o.PasswordSecret)
+		}
+		return password, nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewLDAPConfigFromActiveDirectory(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LDAP_PASSWORD", "from-env")
+
+	kube := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "cattle-global-data", Name: "activedirectoryconfig-serviceaccountpassword"},
+			Data:       map[string][]byte{"serviceaccountpassword": []byte("from-authconfig")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "cattle-system", Name: "migrate"},
+			Data:       map[string][]byte{"password": []byte("from-secret")},
+		},
+	)
+
+	authConfig := &apiv3.ActiveDirectoryConfig{
+		Servers:                []string{"dc1.example.com"},
+		Port:                   389,
+		StartTLS:               true,
+		ConnectionTimeout:      5000,
+		ServiceAccountUsername: "rancher",
+		ServiceAccountPassword: "cattle-global-data:activedirectoryconfig-serviceaccountpassword",
+		DefaultLoginDomain:     "EXAMPLE",
+	}
+
+	tests := []struct {
+		name       string
+		authConfig *apiv3.ActiveDirectoryConfig
+		overrides  *LDAPOverrides
+		want       LDAPConfig
+		wantErr    bool
+	}{
+		{
+			name:       "authconfig",
+			authConfig: authConfig,
+			want: LDAPConfig{
+				Servers: []string{"dc1.example.com"}, Port: 389, StartTLS: true, ConnectionTimeout: 5000,
+				ServiceAccountName: "rancher", ServiceAccountPassword: "from-authconfig", DefaultLoginDomain: "EXAMPLE",
+			},
+		},
+		{
+			name:       "password stored in the authconfig",
+			authConfig: &apiv3.ActiveDirectoryConfig{ServiceAccountUsername: "rancher", ServiceAccountPassword: "inline"},
+			want:       LDAPConfig{ServiceAccountName: "rancher", ServiceAccountPassword: "inline"},
+		},
+		{
+			name:       "overrides",
+			authConfig: authConfig,
+			overrides: &LDAPOverrides{
+				Servers: []string{"dc2.example.com", "dc3.example.com"}, Port: 636, TLSMode: TLSModeTLS,
+				BindDN: "CN=svc-migrate,OU=Service Accounts,DC=example,DC=com", Timeout: 30 * time.Second,
+				PasswordEnv: "LDAP_PASSWORD",
+			},
+			want: LDAPConfig{
+				Servers: []string{"dc2.example.com", "dc3.example.com"}, Port: 636, TLS: true, ConnectionTimeout: 30000,
+				ServiceAccountName: "CN=svc-migrate,OU=Service Accounts,DC=example,DC=com", ServiceAccountPassword: "from-env",
+			},
+		},
+		{
+			name:       "empty overrides",
+			authConfig: authConfig,
+			overrides:  &LDAPOverrides{},
+			want: LDAPConfig{
+				Servers: []string{"dc1.example.com"}, Port: 389, StartTLS: true, ConnectionTimeout: 5000,
+				ServiceAccountName: "rancher", ServiceAccountPassword: "from-authconfig", DefaultLoginDomain: "EXAMPLE",
+			},
+		},
+		{
+			name: "password file without reading the authconfig Secret",
+			authConfig: &apiv3.ActiveDirectoryConfig{
+				ServiceAccountUsername: "rancher", ServiceAccountPassword: "cattle-global-data:not-found",
+			},
+			overrides: &LDAPOverrides{PasswordFile: passwordFile, TLSMode: TLSModeNone},
+			want:      LDAPConfig{ServiceAccountName: "rancher", ServiceAccountPassword: "from-file"},
+		},
+		{
+			name:       "password Secret with key",
+			authConfig: authConfig,
+			overrides:  &LDAPOverrides{PasswordSecret: "cattle-system:migrate:password", TLSMode: TLSModeStartTLS},
+			want: LDAPConfig{
+				Servers: []string{"dc1.example.com"}, Port: 389, StartTLS: true, ConnectionTimeout: 5000,
+				ServiceAccountName: "rancher", ServiceAccountPassword: "from-secret", DefaultLoginDomain: "EXAMPLE",
+			},
+		},
+		{
+			name:       "authconfig Secret not found",
+			authConfig: &apiv3.ActiveDirectoryConfig{ServiceAccountPassword: "cattle-global-data:not-found"},
+			wantErr:    true,
+		},
+		{
+			name:       "invalid TLS mode",
+			authConfig: authConfig,
+			overrides:  &LDAPOverrides{TLSMode: "ssl"},
+			wantErr:    true,
+		},
+		{
+			name:       "multiple password sources",
+			authConfig: authConfig,
+			overrides:  &LDAPOverrides{PasswordFile: passwordFile, PasswordEnv: "LDAP_PASSWORD"},
+			wantErr:    true,
+		},
+		{
+			name:       "empty password file",
+			authConfig: authConfig,
+			overrides:  &LDAPOverrides{PasswordFile: emptyFile},
+			wantErr:    true,
+		},
+		{
+			name:       "unset environment variable",
+			authConfig: authConfig,
+			overrides:  &LDAPOverrides{PasswordEnv: "LDAP_PASSWORD_UNSET"},
+			wantErr:    true,
+		},
+		{
+			name:       "missing key of the password Secret",
+			authConfig: authConfig,
+			overrides:  &LDAPOverrides{PasswordSecret: "cattle-system:migrate"},
+			wantErr:    true,
+		},
+		{
+			name:       "invalid CA file",
+			authConfig: authConfig,
+			overrides:  &LDAPOverrides{CAFile: passwordFile},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewLDAPConfigFromActiveDirectory(context.Background(), kube.CoreV1(), tt.authConfig, tt.overrides)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewLDAPConfigFromActiveDirectory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got.CAPool == nil {
+				t.Error("NewLDAPConfigFromActiveDirectory() has no CA pool")
+			}
+			got.CAPool = nil
+
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("NewLDAPConfigFromActiveDirectory() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package client
 
 import (
+	"context"
+	"fmt"
+
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/client-go/kubernetes"
@@ -40,3 +43,30 @@ func NewRancherClient(config *rest.Config) (*RancherClient, error) {
 		Rancher: restClient,
 	}, nil
 }
+
+// ServerVersion returns the value of the server-version setting of Rancher
+func (c *RancherClient) ServerVersion(ctx context.Context) (string, error) {
+	setting := &apiv3.Setting{}
+	err := c.Rancher.Get().Resource("settings").Name("server-version").Do(ctx).Into(setting)
+	if err != nil {
+		return "", fmt.Errorf("getting server-version setting: %w", err)
+	}
+	return setting.Value, nil
+}
+
+// EnabledAuthProviders returns the names of the enabled authconfigs
+func (c *RancherClient) EnabledAuthProviders(ctx context.Context) ([]string, error) {
+	authConfigs := &apiv3.AuthConfigList{}
+	err := c.Rancher.Get().Resource("authconfigs").Do(ctx).Into(authConfigs)
+	if err != nil {
+		return nil, fmt.Errorf("listing authconfigs: %w", err)
+	}
+
+	var enabled []string
+	for _, authConfig := range authConfigs.Items {
+		if authConfig.Enabled {
+			enabled = append(enabled, authConfig.Name)
+		}
+	}
+	return enabled, nil
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// defaultConnectionTimeout is used when the LDAPConfig has no connection timeout
+const defaultConnectionTimeout = 5 * time.Second
+
+// VerifyTLS connects to a server of the LDAPConfig with TLS or StartTLS, verifying its certificate chain
+// with the CA pool of the config, and returns the certificate of the server
+func VerifyTLS(config *LDAPConfig, server string) (*x509.Certificate, error) {
+	if !config.TLS && !config.StartTLS {
+		return nil, errors.New("TLS is not enabled")
+	}
+
+	addr := net.JoinHostPort(server, strconv.FormatInt(config.Port, 10))
+	dialer := &net.Dialer{Timeout: defaultConnectionTimeout}
+	if config.ConnectionTimeout > 0 {
+		dialer.Timeout = time.Duration(config.ConnectionTimeout) * time.Millisecond
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs:    config.CAPool,
+		ServerName: server,
+	}
+
+	var state tls.ConnectionState
+
+	if config.TLS {
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("TLS connection to '%s' failed: %w", addr, err)
+		}
+		defer conn.Close()
+
+		state = conn.ConnectionState()
+	} else {
+		conn, err := ldapv3.DialURL("ldap://"+addr, ldapv3.DialWithDialer(dialer))
+		if err != nil {
+			return nil, fmt.Errorf("connection to '%s' failed: %w", addr, err)
+		}
+		defer conn.Close()
+
+		err = conn.StartTLS(tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("StartTLS on '%s' failed: %w", addr, err)
+		}
+
+		state, _ = conn.TLSConnectionState()
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("no certificates returned by '%s'", addr)
+	}
+
+	return state.PeerCertificates[0], nil
+}
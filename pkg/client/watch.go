@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/runtime/serializer/streaming"
+	"k8s.io/apimachinery/pkg/watch"
+	restclientwatch "k8s.io/client-go/rest/watch"
+)
+
+// Watch watches a Rancher resource in all the namespaces. The objects of the events are decoded into their
+// typed version, as the Rancher client cannot decode them into the internal version.
+func (c *RancherClient) Watch(ctx context.Context, resource string, options metav1.ListOptions) (watch.Interface, error) {
+	rancherScheme := runtime.NewScheme()
+	err := apiv3.AddToScheme(rancherScheme)
+	if err != nil {
+		return nil, err
+	}
+	codecs := serializer.NewCodecFactory(rancherScheme)
+
+	info, found := runtime.SerializerInfoForMediaType(codecs.SupportedMediaTypes(), runtime.ContentTypeJSON)
+	if !found || info.StreamSerializer == nil {
+		return nil, fmt.Errorf("no stream serializer for %s", runtime.ContentTypeJSON)
+	}
+
+	options.Watch = true
+	body, err := c.Rancher.Get().Resource(resource).
+		VersionedParams(&options, metav1.ParameterCodec).
+		Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	frameReader := info.StreamSerializer.Framer.NewFrameReader(body)
+	eventDecoder := streaming.NewDecoder(frameReader, info.StreamSerializer.Serializer)
+
+	return watch.NewStreamWatcher(
+		restclientwatch.NewDecoder(eventDecoder, codecs.UniversalDeserializer()),
+		apierrors.NewClientErrorReporter(http.StatusInternalServerError, http.MethodGet, "ClientWatchDecoding"),
+	), nil
+}
@@ -0,0 +1,57 @@
+// Package logging handles the human readable output and the structured logs of the migrations
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Output is the writer of the human readable output. It is discarded when the logs are written as JSON.
+var Output io.Writer = os.Stdout
+
+// Setup configures the default slog.Logger.
+// With the text format the logs are written to stderr only with verbosity (1 for info, 2 for debug),
+// since the human readable output is already describing every action.
+// With the json format every event is written to stdout (debug events with verbosity),
+// and the human readable output is discarded.
+func Setup(format string, verbosity int) error {
+	var handler slog.Handler
+
+	switch format {
+	case FormatText:
+		level := slog.LevelInfo - slog.Level(4*(verbosity-1))
+		if verbosity == 0 {
+			level = slog.LevelError + 4
+		}
+
+		Output = os.Stdout
+		handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	case FormatJSON:
+		level := slog.LevelInfo - slog.Level(4*verbosity)
+
+		Output = io.Discard
+		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})
+	default:
+		return fmt.Errorf("invalid log format '%s': must be one of %s or %s", format, FormatText, FormatJSON)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// Printf writes the formatted human readable output
+func Printf(format string, a ...any) {
+	fmt.Fprintf(Output, format, a...)
+}
+
+// Println writes the human readable output followed by a newline
+func Println(a ...any) {
+	fmt.Fprintln(Output, a...)
+}
@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	logger, output := slog.Default(), Output
+	t.Cleanup(func() {
+		slog.SetDefault(logger)
+		Output = output
+	})
+
+	tests := []struct {
+		name       string
+		format     string
+		verbosity  int
+		wantOutput io.Writer
+		wantLevels map[slog.Level]bool
+		wantErr    bool
+	}{
+		{
+			name:       "text without verbosity",
+			format:     FormatText,
+			wantOutput: os.Stdout,
+			wantLevels: map[slog.Level]bool{slog.LevelDebug: false, slog.LevelInfo: false, slog.LevelError: false},
+		},
+		{
+			name:       "text with verbosity 1",
+			format:     FormatText,
+			verbosity:  1,
+			wantOutput: os.Stdout,
+			wantLevels: map[slog.Level]bool{slog.LevelDebug: false, slog.LevelInfo: true, slog.LevelWarn: true},
+		},
+		{
+			name:       "text with verbosity 2",
+			format:     FormatText,
+			verbosity:  2,
+			wantOutput: os.Stdout,
+			wantLevels: map[slog.Level]bool{slog.LevelDebug: true, slog.LevelInfo: true},
+		},
+		{
+			name:       "json without verbosity",
+			format:     FormatJSON,
+			wantOutput: io.Discard,
+			wantLevels: map[slog.Level]bool{slog.LevelDebug: false, slog.LevelInfo: true},
+		},
+		{
+			name:       "json with verbosity",
+			format:     FormatJSON,
+			verbosity:  1,
+			wantOutput: io.Discard,
+			wantLevels: map[slog.Level]bool{slog.LevelDebug: true, slog.LevelInfo: true},
+		},
+		{
+			name:    "invalid format",
+			format:  "yaml",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Setup(tt.format, tt.verbosity)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if Output != tt.wantOutput {
+				t.Errorf("Output = %v, want %v", Output, tt.wantOutput)
+			}
+			for level, want := range tt.wantLevels {
+				if got := slog.Default().Enabled(context.Background(), level); got != want {
+					t.Errorf("level %s enabled = %v, want %v", level, got, want)
+				}
+			}
+		})
+	}
+}
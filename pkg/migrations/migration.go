@@ -0,0 +1,167 @@
+// Package migrations defines the versioned migrations and the registry where they are plugged in
+package migrations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"time"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/spf13/pflag"
+)
+
+// Migration is a versioned migration of the Rancher resources.
+// Every registered Migration gets its check, migrate and rollback commands.
+type Migration interface {
+	// ID is the identifier of the migration, used as the name of its command (i.e. "v1.10.0")
+	ID() string
+	// Description is a short description of the migration
+	Description() string
+
+	// AddFlags adds the flags of the migration to the flag set shared by its commands
+	AddFlags(fs *pflag.FlagSet)
+	// Init prepares the migration before running any of its commands (i.e. connecting to external services).
+	// The flag set is the parsed one of the running command.
+	Init(ctx context.Context, c *client.RancherClient, fs *pflag.FlagSet) error
+
+	// Applicable returns whether the migration applies to the Rancher server, whose version is the value of its
+	// server-version setting. It can be called without Init.
+	Applicable(ctx context.Context, c *client.RancherClient, serverVersion string) (bool, error)
+	// Applied returns whether the migration was already applied to all the resources. It can be called without Init.
+	Applied(ctx context.Context, c *client.RancherClient) (bool, error)
+	// Discover finds the resources handled by the migration
+	Discover(ctx context.Context) (Discovery, error)
+	// Plan returns the changes migrating the discovered resources of the Selection.
+	// It fails if any of the values of the Selection does not match a discovered resource.
+	Plan(ctx context.Context, discovery Discovery, selection Selection) (Plan, error)
+	// PlanRollback returns the changes reverting the migrated resources of the Selection
+	PlanRollback(ctx context.Context, discovery Discovery, selection Selection) (Plan, error)
+	// LoadPlan reads a Plan saved with Plan.Save, for the current resources
+	LoadPlan(ctx context.Context, path string) (Plan, error)
+	// Apply applies the changes of a Plan
+	Apply(ctx context.Context, plan Plan, opts ApplyOptions) error
+	// Verify checks that all the resources were migrated correctly. The error is returned only
+	// if the verification could not be completed, the failed checks are reported in the results.
+	Verify(ctx context.Context) ([]CheckResult, error)
+
+	// Permissions returns the access to the Kubernetes API needed to apply and roll back the migration
+	Permissions() []Permission
+	// Preflight checks the requirements of the migration that are not Kubernetes permissions
+	// (i.e. the connectivity to external services). It is called without Init, on the parsed flag set.
+	Preflight(ctx context.Context, c *client.RancherClient, fs *pflag.FlagSet) []CheckResult
+}
+
+// Inspector is implemented by the migrations that can show all the resources referencing an identity
+type Inspector interface {
+	// Inspect prints the resources referencing the identity
+	Inspect(ctx context.Context, identity string) error
+}
+
+// MappingExporter is implemented by the migrations that can export how the discovered identities were resolved
+type MappingExporter interface {
+	// ExportMapping writes the resolved identities of the Discovery in the file
+	ExportMapping(ctx context.Context, discovery Discovery, path string) error
+}
+
+// IdentityResolver is implemented by the migrations that can translate the identities between their formats
+type IdentityResolver interface {
+	// ResolveIdentities prints all the representations of the identities
+	ResolveIdentities(ctx context.Context, identities []string) error
+}
+
+// Maintainer is implemented by the migrations that can restrict the logins while the resources are updated,
+// so that no new identities are created meanwhile
+type Maintainer interface {
+	// StartMaintenance restricts the logins, if enabled by the flags, before applying a Plan with the options,
+	// until the returned restore is called. It fails if a previous maintenance was not restored.
+	StartMaintenance(ctx context.Context, opts ApplyOptions) (restore func() error, err error)
+	// RestoreMaintenance restores the logins of a maintenance that was not restored. It can be called without Init.
+	RestoreMaintenance(ctx context.Context, c *client.RancherClient) error
+}
+
+// Discovery is the result of the discovery of the resources of a Migration
+type Discovery interface {
+	// Print writes the human readable report of the discovered resources
+	Print()
+	// Pending returns the IDs of the items still to be migrated
+	Pending() []string
+	// Migrated returns the IDs of the items already migrated
+	Migrated() []string
+}
+
+// Plan is the list of changes of a migration or a rollback
+type Plan interface {
+	// Operation is the operation of the plan (OperationMigrate or OperationRollback)
+	Operation() string
+	// Print writes the human readable list of the changes
+	Print()
+	// IDs returns the IDs of the items changed by the plan
+	IDs() []string
+	// Save writes the plan in a file, to be applied later
+	Save(path string) error
+
+	// Counts returns the number of the changed resources by kind, for the confirmation of the plan
+	Counts() []Count
+	// Summary returns a one-line description of an item, for the interactive selection
+	Summary(id string) string
+	// PrintDetail writes the details of the changes of an item in the writer
+	PrintDetail(out io.Writer, id string)
+	// Select returns the Plan of the changes of the items with the IDs only
+	Select(ids []string) Plan
+}
+
+// Count is the number of the resources of a kind changed by a Plan
+type Count struct {
+	Kind  string
+	Count int
+}
+
+// Permission is an access to the Kubernetes API. An empty Namespace means all the namespaces.
+type Permission struct {
+	Verb      string
+	Group     string
+	Resource  string
+	Namespace string
+}
+
+// CheckResult is the outcome of a preflight or verification check, failed if Err is not nil
+type CheckResult struct {
+	Name string
+	Err  error
+}
+
+// ApplyOptions configure how a Plan is applied
+type ApplyOptions struct {
+	// StepTimeout is the timeout of the changes of a single item
+	StepTimeout time.Duration
+	// StateFile is where the pending items are saved when the apply is interrupted or fails
+	StateFile string
+	// RunID identifies the apply in the changes of the objects. A new one is generated if empty.
+	RunID string
+
+	// BatchSize applies the items in waves of BatchSize items, if Waves are not provided
+	BatchSize int
+	// Waves are the IDs of the items of every wave
+	Waves [][]string
+	// Pause is the time waited between two waves
+	Pause time.Duration
+	// MaxFailureRate is the rate (0-1) of failed items of a wave over which the apply is stopped
+	MaxFailureRate float64
+
+	// Diff prints the changes of every object before updating it
+	Diff bool
+	// DryRun prints the changes of every object without updating them
+	DryRun bool
+
+	// Watch keeps updating the new items appearing for this duration after the apply
+	Watch time.Duration
+}
+
+// NewRunID returns a new ID identifying a run in the changes of the objects, i.e. "20240102-150405-1a2b3c"
+func NewRunID() string {
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]Migration{}
+)
+
+// Register makes a Migration available to the CLI. It is meant to be called in the init function
+// of the package of the migration, and it panics if a migration with the same ID is already registered.
+func Register(m Migration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, found := registry[m.ID()]; found {
+		panic(fmt.Sprintf("migration %s already registered", m.ID()))
+	}
+	registry[m.ID()] = m
+}
+
+// Get returns the registered Migration with the ID
+func Get(id string) (Migration, bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	m, found := registry[id]
+	return m, found
+}
+
+// All returns the registered migrations sorted by version
+func All() []Migration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	all := make([]Migration, 0, len(registry))
+	for _, m := range registry {
+		all = append(all, m)
+	}
+
+	slices.SortFunc(all, func(m1, m2 Migration) int {
+		v1, err1 := version.ParseGeneric(m1.ID())
+		v2, err2 := version.ParseGeneric(m2.ID())
+		if err1 != nil || err2 != nil {
+			return strings.Compare(m1.ID(), m2.ID())
+		}
+		switch {
+		case v1.LessThan(v2):
+			return -1
+		case v2.LessThan(v1):
+			return 1
+		}
+		return 0
+	})
+
+	return all
+}
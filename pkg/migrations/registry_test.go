@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+)
+
+// stubMigration is a Migration with only an ID
+type stubMigration struct {
+	Migration
+	id string
+}
+
+func (m stubMigration) ID() string { return m.id }
+
+func TestRegistry(t *testing.T) {
+	registered := registry
+	registry = map[string]Migration{}
+	t.Cleanup(func() { registry = registered })
+
+	for _, id := range []string{"v1.10.0", "v1.2.0", "v1.9.1", "v2.0.0-alpha1"} {
+		Register(stubMigration{id: id})
+	}
+
+	var ids []string
+	for _, m := range All() {
+		ids = append(ids, m.ID())
+	}
+	if want := []string{"v1.2.0", "v1.9.1", "v1.10.0", "v2.0.0-alpha1"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("All() = %v, want sorted by version %v", ids, want)
+	}
+
+	if m, found := Get("v1.10.0"); !found || m.ID() != "v1.10.0" {
+		t.Errorf("Get(v1.10.0) = %v, %v", m, found)
+	}
+	if _, found := Get("v1.11.0"); found {
+		t.Error("Get(v1.11.0) found a migration not registered")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() of a duplicated ID did not panic")
+		}
+	}()
+	Register(stubMigration{id: "v1.10.0"})
+}
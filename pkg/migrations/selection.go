@@ -0,0 +1,35 @@
+package migrations
+
+// Selection selects the items of a Discovery to plan. An item is selected if it matches
+// any of the values of every non-empty selector, and none of the excluded values.
+// An empty Selection selects all the items.
+type Selection struct {
+	// IDs are the exact IDs of the items
+	IDs []string
+	// Users are the usernames (or the names of the User resources) of the items
+	Users []string
+	// Clusters are the IDs of the clusters where the items have bindings
+	Clusters []string
+	// Projects are the IDs ("<cluster>:<project>" or "<project>") of the projects where the items have bindings
+	Projects []string
+	// Namespaces are the namespaces of the bindings of the items
+	Namespaces []string
+	// OUs are the DNs of the directory subtrees of the items (i.e. "OU=Sales,DC=example,DC=com")
+	OUs []string
+	// Groups are the DNs of the directory groups with the items as members
+	Groups []string
+	// Exclude are the IDs or the usernames of the items never selected
+	Exclude []string
+}
+
+// Empty returns true if the Selection has no selectors, and selects all the items
+func (s Selection) Empty() bool {
+	return len(s.IDs) == 0 &&
+		len(s.Users) == 0 &&
+		len(s.Clusters) == 0 &&
+		len(s.Projects) == 0 &&
+		len(s.Namespaces) == 0 &&
+		len(s.OUs) == 0 &&
+		len(s.Groups) == 0 &&
+		len(s.Exclude) == 0
+}
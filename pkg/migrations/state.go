@@ -0,0 +1,110 @@
+package migrations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	OperationMigrate  = "migrate"
+	OperationRollback = "rollback"
+)
+
+// State is the progress of an interrupted migration or rollback, used to resume it
+type State struct {
+	Operation     string    `json:"operation"`
+	Completed     []string  `json:"completed"`
+	Pending       []string  `json:"pending"`
+	InterruptedAt time.Time `json:"interruptedAt"`
+	Reason        string    `json:"reason"`
+
+	// Waves are the pending items grouped by wave, when the items are applied in waves
+	Waves [][]string `json:"waves,omitempty"`
+}
+
+// NewState returns the State of an operation interrupted after applying the changes of the first items
+func NewState(operation string, ids []string, applied int, reason error) *State {
+	state := &State{
+		Operation:     operation,
+		Completed:     []string{},
+		Pending:       []string{},
+		InterruptedAt: time.Now().UTC(),
+	}
+
+	if reason != nil {
+		state.Reason = reason.Error()
+	}
+
+	for i, id := range ids {
+		if i < applied {
+			state.Completed = append(state.Completed, id)
+		} else {
+			state.Pending = append(state.Pending, id)
+		}
+	}
+
+	return state
+}
+
+// NewWavesState returns the State of an operation applied in waves, interrupted with the pending waves.
+// The items of the pending waves include the failed items of the completed waves.
+func NewWavesState(operation string, completed []string, pending [][]string, reason error) *State {
+	state := &State{
+		Operation:     operation,
+		Completed:     append([]string{}, completed...),
+		Pending:       []string{},
+		Waves:         [][]string{},
+		InterruptedAt: time.Now().UTC(),
+	}
+
+	if reason != nil {
+		state.Reason = reason.Error()
+	}
+
+	for _, wave := range pending {
+		if len(wave) > 0 {
+			state.Pending = append(state.Pending, wave...)
+			state.Waves = append(state.Waves, wave)
+		}
+	}
+
+	return state
+}
+
+// LoadState reads the State saved in the file
+func LoadState(path string) (*State, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state file: %w", err)
+	}
+
+	state := &State{}
+	err = json.Unmarshal(b, state)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse state file '%s': %w", path, err)
+	}
+
+	return state, nil
+}
+
+// Save writes the State in the file
+func (s *State) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o600)
+}
+
+// RemoveState deletes the state file, if present
+func RemoveState(path string) error {
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot remove state file: %w", err)
+	}
+	return nil
+}
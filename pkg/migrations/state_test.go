@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewState(t *testing.T) {
+	ids := []string{"a", "b", "c"}
+
+	tests := []struct {
+		name          string
+		applied       int
+		wantCompleted []string
+		wantPending   []string
+	}{
+		{name: "none applied", applied: 0, wantCompleted: []string{}, wantPending: []string{"a", "b", "c"}},
+		{name: "some applied", applied: 2, wantCompleted: []string{"a", "b"}, wantPending: []string{"c"}},
+		{name: "all applied", applied: 3, wantCompleted: []string{"a", "b", "c"}, wantPending: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := NewState(OperationMigrate, ids, tt.applied, errors.New("interrupted"))
+
+			if !reflect.DeepEqual(state.Completed, tt.wantCompleted) || !reflect.DeepEqual(state.Pending, tt.wantPending) {
+				t.Errorf("NewState() = completed %v, pending %v, want %v, %v", state.Completed, state.Pending, tt.wantCompleted, tt.wantPending)
+			}
+			if state.Operation != OperationMigrate || state.Reason != "interrupted" || state.InterruptedAt.IsZero() {
+				t.Errorf("NewState() = %+v", state)
+			}
+		})
+	}
+}
+
+func TestNewWavesState(t *testing.T) {
+	state := NewWavesState(OperationRollback, []string{"a"}, [][]string{{"b", "c"}, {}, {"d"}}, nil)
+
+	if !reflect.DeepEqual(state.Waves, [][]string{{"b", "c"}, {"d"}}) {
+		t.Errorf("waves = %v, want the empty waves dropped", state.Waves)
+	}
+	if !reflect.DeepEqual(state.Pending, []string{"b", "c", "d"}) || !reflect.DeepEqual(state.Completed, []string{"a"}) {
+		t.Errorf("NewWavesState() = completed %v, pending %v", state.Completed, state.Pending)
+	}
+	if state.Reason != "" {
+		t.Errorf("reason = %q, want empty", state.Reason)
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state := NewWavesState(OperationMigrate, []string{"a"}, [][]string{{"b"}, {"c"}}, errors.New("wave 2 failed"))
+	if err := state.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.InterruptedAt.Equal(state.InterruptedAt) {
+		t.Errorf("interruptedAt = %v, want %v", loaded.InterruptedAt, state.InterruptedAt)
+	}
+	loaded.InterruptedAt = state.InterruptedAt
+	if !reflect.DeepEqual(loaded, state) {
+		t.Errorf("LoadState() = %+v, want %+v", loaded, state)
+	}
+
+	if err := RemoveState(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadState(path); err == nil {
+		t.Error("LoadState() of a removed state file did not fail")
+	}
+	if err := RemoveState(path); err != nil {
+		t.Errorf("RemoveState() of a missing state file error = %v", err)
+	}
+}
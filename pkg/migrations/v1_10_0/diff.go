@@ -0,0 +1,175 @@
+package version_1_10_0
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/yaml"
+)
+
+// ObjectDiff is the change of an object updated by the migration, as a unified diff of its YAML
+type ObjectDiff struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Recreated is true if the object is deleted and created again with a new name
+	Recreated bool
+	Diff      string
+}
+
+// diffResource returns the diffs of the objects of the principal updated to its updated principal ID,
+// with the annotations of the run. The objects of the principal are not modified.
+func diffResource(res *MigratableResource, runID string) ([]ObjectDiff, error) {
+	updatedPrincipalID := GetUpdatedPrincipalID(res)
+	now := time.Now()
+
+	var diffs []ObjectDiff
+
+	add := func(kind, namespace, name string, recreated bool, before, after any) error {
+		diff, err := diffYAML(before, after, kind, namespace, name, recreated)
+		if err != nil {
+			return fmt.Errorf("cannot diff %s '%s': %w", kind, name, err)
+		}
+
+		diffs = append(diffs, ObjectDiff{
+			Kind:      kind,
+			Namespace: namespace,
+			Name:      name,
+			Recreated: recreated,
+			Diff:      diff,
+		})
+		return nil
+	}
+
+	if res.User != nil {
+		user := res.User.DeepCopy()
+		for i, principalID := range user.PrincipalIDs {
+			if principalID == res.PrincipalID {
+				user.PrincipalIDs[i] = updatedPrincipalID
+			}
+		}
+		annotate(user, res.PrincipalID, runID, now)
+
+		if err := add("User", "", res.User.Name, false, res.User, user); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, prtb := range GetResourceByType[*PRTBResource](res.Bindings) {
+		updated := prtb.PRTB.DeepCopy()
+		updated.Name, updated.ResourceVersion = "", ""
+		updated.UserPrincipalName = updatedPrincipalID
+		annotate(updated, res.PrincipalID, runID, now)
+
+		err := add("ProjectRoleTemplateBinding", prtb.PRTB.Namespace, prtb.PRTB.Name, true, prtb.PRTB, updated)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, crtb := range GetResourceByType[*CRTBResource](res.Bindings) {
+		updated := crtb.CRTB.DeepCopy()
+		updated.Name, updated.ResourceVersion = "", ""
+		updated.UserPrincipalName = updatedPrincipalID
+		annotate(updated, res.PrincipalID, runID, now)
+
+		err := add("ClusterRoleTemplateBinding", crtb.CRTB.Namespace, crtb.CRTB.Name, true, crtb.CRTB, updated)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, token := range GetResourceByType[*TokenResource](res.Bindings) {
+		updated := token.Token.DeepCopy()
+		updated.UserPrincipal.Name = updatedPrincipalID
+		annotate(updated, res.PrincipalID, runID, now)
+
+		if err := add("Token", "", token.Token.Name, false, token.Token, updated); err != nil {
+			return nil, err
+		}
+	}
+
+	return diffs, nil
+}
+
+// diffYAML returns the unified diff of the YAML of the objects
+func diffYAML(before, after any, kind, namespace, name string, recreated bool) (string, error) {
+	a, err := yaml.Marshal(before)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := yaml.Marshal(after)
+	if err != nil {
+		return "", err
+	}
+
+	object := kind + " " + name
+	if namespace != "" {
+		object = kind + " " + namespace + "/" + name
+	}
+
+	toFile := object
+	if recreated {
+		// the object is deleted, and created again with a name generated by the server
+		object += " (deleted)"
+		toFile = kind + " " + namespace + "/<generated> (created)"
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(a),
+		B:        splitLines(b),
+		FromFile: object,
+		ToFile:   toFile,
+		Context:  3,
+	})
+}
+
+// splitLines returns the lines of the YAML, with their line terminators
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// printDiffs prints the colorized diffs, and logs them in the audit log
+func printDiffs(res *MigratableResource, runID string) error {
+	diffs, err := diffResource(res, runID)
+	if err != nil {
+		return err
+	}
+
+	for _, diff := range diffs {
+		slog.Info("object diff",
+			"principal", res.PrincipalID,
+			"kind", diff.Kind,
+			"namespace", diff.Namespace,
+			"name", diff.Name,
+			"recreated", diff.Recreated,
+			"diff", diff.Diff,
+		)
+
+		for _, line := range strings.SplitAfter(diff.Diff, "\n") {
+			switch {
+			case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+				logging.Printf("%s", yellow(line))
+			case strings.HasPrefix(line, "@@"):
+				logging.Printf("%s", blue(line))
+			case strings.HasPrefix(line, "-"):
+				logging.Printf("%s", red(line))
+			case strings.HasPrefix(line, "+"):
+				logging.Printf("%s", green(line))
+			default:
+				logging.Printf("%s", line)
+			}
+		}
+	}
+
+	return nil
+}
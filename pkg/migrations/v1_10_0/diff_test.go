@@ -0,0 +1,117 @@
+package version_1_10_0
+
+import (
+	"strings"
+	"testing"
+
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiffResource(t *testing.T) {
+	const (
+		principalID        = "activedirectory_user://CN=john,DC=example,DC=com"
+		updatedPrincipalID = "activedirectory_user://objectGUID=00112233-4455-6677-8899-aabbccddeeff"
+	)
+
+	objectGUID, err := guid.Parse("00112233-4455-6677-8899-aabbccddeeff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := &MigratableResource{
+		PrincipalID: principalID,
+		DN:          "CN=john,DC=example,DC=com",
+		GUID:        objectGUID,
+		User: &apiv3.User{
+			ObjectMeta:   metav1.ObjectMeta{Name: "u-john"},
+			PrincipalIDs: []string{"local://u-john", principalID},
+		},
+		Bindings: []PrincipalIDResource{
+			&PRTBResource{PRTB: &apiv3.ProjectRoleTemplateBinding{
+				ObjectMeta:        metav1.ObjectMeta{Name: "prtb-1", Namespace: "p-1", ResourceVersion: "10"},
+				ProjectName:       "c-1:p-1",
+				UserPrincipalName: principalID,
+			}},
+			&TokenResource{Token: &apiv3.Token{
+				ObjectMeta:    metav1.ObjectMeta{Name: "token-1"},
+				UserPrincipal: apiv3.Principal{ObjectMeta: metav1.ObjectMeta{Name: principalID}},
+			}},
+		},
+	}
+
+	diffs, err := diffResource(res, "run-1")
+	if err != nil {
+		t.Fatalf("diffResource() error = %v", err)
+	}
+
+	tests := []struct {
+		kind          string
+		name          string
+		wantRecreated bool
+		wantLines     []string
+	}{
+		{
+			kind: "User",
+			name: "u-john",
+			wantLines: []string{
+				"--- User u-john\n",
+				"+++ User u-john\n",
+				"-- " + principalID + "\n",
+				"+- " + updatedPrincipalID + "\n",
+				"+    " + RunIDAnnotation + ": run-1\n",
+			},
+		},
+		{
+			kind:          "ProjectRoleTemplateBinding",
+			name:          "prtb-1",
+			wantRecreated: true,
+			wantLines: []string{
+				"--- ProjectRoleTemplateBinding p-1/prtb-1 (deleted)\n",
+				"+++ ProjectRoleTemplateBinding p-1/<generated> (created)\n",
+				"-  name: prtb-1\n",
+				"-  resourceVersion: \"10\"\n",
+				"-userPrincipalName: " + principalID + "\n",
+				"+userPrincipalName: " + updatedPrincipalID + "\n",
+			},
+		},
+		{
+			kind: "Token",
+			name: "token-1",
+			wantLines: []string{
+				"--- Token token-1\n",
+				"-    name: " + principalID + "\n",
+				"+    name: " + updatedPrincipalID + "\n",
+				"+    " + PreviousPrincipalAnnotation + ": " + principalID + "\n",
+			},
+		},
+	}
+
+	if len(diffs) != len(tests) {
+		t.Fatalf("diffResource() returned %d diffs, want %d", len(diffs), len(tests))
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			diff := diffs[i]
+			if diff.Kind != tt.kind || diff.Name != tt.name || diff.Recreated != tt.wantRecreated {
+				t.Errorf("diff = %s %s recreated %t, want %s %s recreated %t",
+					diff.Kind, diff.Name, diff.Recreated, tt.kind, tt.name, tt.wantRecreated)
+			}
+			for _, line := range tt.wantLines {
+				if !strings.Contains(diff.Diff, line) {
+					t.Errorf("diff does not contain %q:\n%s", line, diff.Diff)
+				}
+			}
+		})
+	}
+
+	// the objects of the principal are not modified
+	if res.User.PrincipalIDs[1] != principalID || res.User.Annotations != nil {
+		t.Errorf("user = %v %v, want not modified", res.User.PrincipalIDs, res.User.Annotations)
+	}
+	if prtb := GetResourceByType[*PRTBResource](res.Bindings)[0].PRTB; prtb.Name != "prtb-1" || prtb.UserPrincipalName != principalID {
+		t.Errorf("PRTB = %s %s, want not modified", prtb.Name, prtb.UserPrincipalName)
+	}
+}
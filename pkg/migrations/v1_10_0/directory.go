@@ -0,0 +1,133 @@
+package version_1_10_0
+
+import (
+	"fmt"
+	"strings"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+)
+
+// DirectoryEntry is an Active Directory user exported with its DN and objectGUID
+type DirectoryEntry struct {
+	DN   string
+	GUID guid.GUID
+	// SAMAccountName is the sAMAccountName of the user, if exported
+	SAMAccountName string
+	// AccountNames are the sAMAccountName and the userPrincipalName of the user, if exported
+	AccountNames []string
+	// MemberOf are the DNs of the groups of the user, if exported
+	MemberOf []string
+}
+
+// DirectoryResolver resolves the users from the entries of an export of Active Directory,
+// without connecting to it
+type DirectoryResolver struct {
+	// Name identifies the source of the entries in the output (i.e. the path of the export)
+	Name string
+
+	// RequireExactDN disables the lookup by account name of the users whose DN cannot be found
+	RequireExactDN bool
+
+	byDN          map[string]*DirectoryEntry
+	byGUID        map[string]*DirectoryEntry
+	byAccountName map[string][]*DirectoryEntry
+}
+
+// NewDirectoryResolver returns a DirectoryResolver of the entries. DNs and account names are case insensitive.
+func NewDirectoryResolver(name string, entries []DirectoryEntry) *DirectoryResolver {
+	r := &DirectoryResolver{
+		Name:          name,
+		byDN:          map[string]*DirectoryEntry{},
+		byGUID:        map[string]*DirectoryEntry{},
+		byAccountName: map[string][]*DirectoryEntry{},
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+
+		r.byDN[strings.ToLower(entry.DN)] = entry
+		r.byGUID[entry.GUID.UUID()] = entry
+		for _, accountName := range entry.AccountNames {
+			key := strings.ToLower(accountName)
+			r.byAccountName[key] = append(r.byAccountName[key], entry)
+		}
+	}
+
+	return r
+}
+
+// GetGUID returns the objectGUID of the user with the provided DN. If the DN is not found
+// the user is searched by the account name in its RDN, unless RequireExactDN is set.
+func (r *DirectoryResolver) GetGUID(dn string) (guid.GUID, Match, error) {
+	if entry, found := r.byDN[strings.ToLower(dn)]; found {
+		return entry.GUID, r.newMatch(entry, ""), nil
+	}
+
+	if !r.RequireExactDN {
+		parsedDN, err := ldapv3.ParseDN(dn)
+		if err == nil && len(parsedDN.RDNs) > 0 && len(parsedDN.RDNs[0].Attributes) > 0 {
+			objectGUID, match, err := r.GetGUIDByAccountName(parsedDN.RDNs[0].Attributes[0].Value)
+			if err == nil {
+				return objectGUID, match, nil
+			}
+		}
+	}
+
+	return nil, Match{}, fmt.Errorf("%w: no user with DN '%s' in %s", errUserNotFound, dn, r.Name)
+}
+
+// GetDN returns the DN of the user with the provided objectGUID
+func (r *DirectoryResolver) GetDN(uuid guid.GUID) (Match, error) {
+	entry, found := r.byGUID[uuid.UUID()]
+	if !found {
+		return Match{}, fmt.Errorf("%w: objectGUID '%s' not found in %s", errUserNotFound, uuid.UUID(), r.Name)
+	}
+
+	return r.newMatch(entry, ""), nil
+}
+
+// GetGUIDByAccountName returns the objectGUID of the user with the provided sAMAccountName or userPrincipalName
+func (r *DirectoryResolver) GetGUIDByAccountName(name string) (guid.GUID, Match, error) {
+	entries := r.byAccountName[strings.ToLower(name)]
+
+	switch len(entries) {
+	case 0:
+		return nil, Match{}, fmt.Errorf("%w: no user with account name '%s' in %s", errUserNotFound, name, r.Name)
+	case 1:
+		return entries[0].GUID, r.newMatch(entries[0], "accountName"), nil
+	}
+
+	return nil, Match{}, fmt.Errorf("user is ambiguous: %d users with account name '%s'", len(entries), name)
+}
+
+// GetGroupMembers returns the objectGUIDs of the users with the group in their exported memberOf.
+// Nested groups are not expanded.
+func (r *DirectoryResolver) GetGroupMembers(groupDN string) ([]guid.GUID, error) {
+	var (
+		members  []guid.GUID
+		memberOf bool
+	)
+
+	for _, entry := range r.byGUID {
+		memberOf = memberOf || len(entry.MemberOf) > 0
+
+		for _, group := range entry.MemberOf {
+			if strings.EqualFold(group, groupDN) {
+				members = append(members, entry.GUID)
+				break
+			}
+		}
+	}
+
+	if !memberOf {
+		return nil, fmt.Errorf("cannot get members of group '%s': no memberOf attributes in %s", groupDN, r.Name)
+	}
+	return members, nil
+}
+
+func (r *DirectoryResolver) newMatch(entry *DirectoryEntry, attribute string) Match {
+	match := newMatch(entry.DN, attribute, SearchTarget{Name: r.Name})
+	match.AccountName = entry.SAMAccountName
+	return match
+}
@@ -0,0 +1,61 @@
+package version_1_10_0
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+)
+
+const (
+	EncodingUUID        = "uuid"
+	EncodingByteSwapped = "byte-swapped"
+	EncodingBase64      = "base64"
+	EncodingEscaped     = "escaped"
+)
+
+// ParseGUID parses an objectGUID in one of the supported encodings, and returns the detected encoding:
+//   - uuid: the RFC4122 string, optionally in braces ("00112233-4455-6677-8899-aabbccddeeff")
+//   - byte-swapped: the hex of the bytes stored in Active Directory, optionally separated by spaces
+//     ("33221100554477668899aabbccddeeff")
+//   - base64: the base64 of the stored bytes, as shown by ldapsearch ("MyIRAFVEd2aImaq7zN3u/w==")
+//   - escaped: the LDAP filter escaped bytes ("\33\22\11\00...")
+func ParseGUID(value string) (guid.GUID, string, error) {
+	value = strings.TrimSpace(value)
+
+	switch {
+	case strings.HasPrefix(value, `\`):
+		b, err := hex.DecodeString(strings.ReplaceAll(value, `\`, ""))
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid escaped objectGUID '%s': %w", value, err)
+		}
+		objectGUID, err := guid.New(b)
+		return objectGUID, EncodingEscaped, err
+
+	case strings.Contains(value, "-"):
+		objectGUID, err := guid.Parse(strings.Trim(value, "{}"))
+		return objectGUID, EncodingUUID, err
+	}
+
+	if b, err := hex.DecodeString(strings.ReplaceAll(value, " ", "")); err == nil && len(b) == 16 {
+		return guid.GUID(b), EncodingByteSwapped, nil
+	}
+
+	if b, err := base64.StdEncoding.DecodeString(value); err == nil && len(b) == 16 {
+		return guid.GUID(b), EncodingBase64, nil
+	}
+
+	return nil, "", fmt.Errorf("unknown encoding of objectGUID '%s'", value)
+}
+
+// ByteSwapped returns the hex of the bytes of the objectGUID as stored in Active Directory
+func ByteSwapped(objectGUID guid.GUID) string {
+	return hex.EncodeToString(objectGUID.Bytes())
+}
+
+// Base64 returns the base64 of the bytes of the objectGUID, as shown by ldapsearch
+func Base64(objectGUID guid.GUID) string {
+	return base64.StdEncoding.EncodeToString(objectGUID.Bytes())
+}
@@ -0,0 +1,64 @@
+package version_1_10_0
+
+import "testing"
+
+func TestParseGUID(t *testing.T) {
+	const uuid = "00112233-4455-6677-8899-aabbccddeeff"
+
+	tests := []struct {
+		name         string
+		value        string
+		wantEncoding string
+		wantErr      bool
+	}{
+		{name: "uuid", value: uuid, wantEncoding: EncodingUUID},
+		{name: "uuid in braces", value: "{" + uuid + "}", wantEncoding: EncodingUUID},
+		{name: "uuid with spaces", value: "  " + uuid + "\n", wantEncoding: EncodingUUID},
+		{name: "byte-swapped", value: "33221100554477668899aabbccddeeff", wantEncoding: EncodingByteSwapped},
+		{name: "byte-swapped with spaces", value: "33 22 11 00 55 44 77 66 88 99 aa bb cc dd ee ff", wantEncoding: EncodingByteSwapped},
+		{name: "base64", value: "MyIRAFVEd2aImaq7zN3u/w==", wantEncoding: EncodingBase64},
+		{name: "escaped", value: `\33\22\11\00\55\44\77\66\88\99\aa\bb\cc\dd\ee\ff`, wantEncoding: EncodingEscaped},
+		{name: "invalid uuid", value: "00112233-4455-6677-8899", wantErr: true},
+		{name: "invalid escaped", value: `\33\zz`, wantErr: true},
+		{name: "short escaped", value: `\33\22\11\00`, wantErr: true},
+		{name: "short hex", value: "33221100", wantErr: true},
+		{name: "unknown", value: "not a guid", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objectGUID, encoding, err := ParseGUID(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseGUID(%q) expected error, got %s (%s)", tt.value, objectGUID.UUID(), encoding)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseGUID(%q) unexpected error: %v", tt.value, err)
+			}
+
+			if encoding != tt.wantEncoding {
+				t.Errorf("ParseGUID(%q) encoding = %s, want %s", tt.value, encoding, tt.wantEncoding)
+			}
+			if got := objectGUID.UUID(); got != uuid {
+				t.Errorf("ParseGUID(%q) = %s, want %s", tt.value, got, uuid)
+			}
+		})
+	}
+}
+
+func TestGUIDEncodings(t *testing.T) {
+	objectGUID, _, err := ParseGUID("00112233-4455-6677-8899-aabbccddeeff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := ByteSwapped(objectGUID), "33221100554477668899aabbccddeeff"; got != want {
+		t.Errorf("ByteSwapped() = %s, want %s", got, want)
+	}
+	if got, want := Base64(objectGUID), "MyIRAFVEd2aImaq7zN3u/w=="; got != want {
+		t.Errorf("Base64() = %s, want %s", got, want)
+	}
+}
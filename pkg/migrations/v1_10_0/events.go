@@ -0,0 +1,102 @@
+package version_1_10_0
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// PreviousPrincipalAnnotation is the principal ID of the object before its last update
+	PreviousPrincipalAnnotation = "rancher-migrate/previous-principal"
+	// RunIDAnnotation is the ID of the run that last updated the object
+	RunIDAnnotation = "rancher-migrate/run-id"
+	// UpdatedAtAnnotation is the time of the last update of the object, in RFC 3339 format
+	UpdatedAtAnnotation = "rancher-migrate/updated-at"
+
+	// eventsComponent is the source of the Events emitted on the users
+	eventsComponent = "rancher-migrate"
+	// eventsNamespace is the namespace of the Events of the users, that are cluster scoped
+	eventsNamespace = metav1.NamespaceDefault
+)
+
+// annotate sets the annotations of the previous principal, the run and the time of the update on the object
+func annotate(obj metav1.Object, previousPrincipalID, runID string, now time.Time) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[PreviousPrincipalAnnotation] = previousPrincipalID
+	annotations[RunIDAnnotation] = runID
+	annotations[UpdatedAtAnnotation] = now.UTC().Format(time.RFC3339)
+
+	obj.SetAnnotations(annotations)
+}
+
+// isRollback returns true if the principal is rolled back from its objectGUID to its DN
+func isRollback(res *MigratableResource) bool {
+	return strings.Contains(res.PrincipalID, ad.ObjectGUIDAttribute)
+}
+
+// emitUserEvent records an Event on the User of the principal, if any, with the outcome of its update.
+// A failure to create the Event is only logged, without failing the update.
+func emitUserEvent(ctx context.Context, c *client.RancherClient, res *MigratableResource, runID string, updateErr error) {
+	if res.User == nil {
+		return
+	}
+
+	updatedPrincipalID := GetUpdatedPrincipalID(res)
+
+	action, reason := "Migrate", "PrincipalMigrated"
+	if isRollback(res) {
+		action, reason = "Rollback", "PrincipalRolledBack"
+	}
+
+	eventType := corev1.EventTypeNormal
+	message := fmt.Sprintf("Principal %s updated to %s (run %s)", res.PrincipalID, updatedPrincipalID, runID)
+	if updateErr != nil {
+		eventType = corev1.EventTypeWarning
+		reason += "Failed"
+		message = fmt.Sprintf("Cannot update principal %s to %s (run %s): %s", res.PrincipalID, updatedPrincipalID, runID, updateErr)
+	}
+
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: res.User.Name + ".",
+			Namespace:    eventsNamespace,
+			Annotations:  map[string]string{RunIDAnnotation: runID},
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      apiv3.SchemeGroupVersion.String(),
+			Kind:            "User",
+			Name:            res.User.Name,
+			UID:             res.User.UID,
+			ResourceVersion: res.User.ResourceVersion,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Action:         action,
+		Source:         corev1.EventSource{Component: eventsComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	_, err := c.Kube.CoreV1().Events(eventsNamespace).Create(ctx, event, metav1.CreateOptions{})
+	if err != nil {
+		slog.Warn("cannot create event", "principal", res.PrincipalID, "user", res.User.Name, "reason", reason, "error", err)
+		return
+	}
+	slog.Debug("event created", "principal", res.PrincipalID, "user", res.User.Name, "reason", reason)
+}
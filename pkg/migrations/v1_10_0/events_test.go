@@ -0,0 +1,94 @@
+package version_1_10_0
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAnnotate(t *testing.T) {
+	user := &apiv3.User{ObjectMeta: metav1.ObjectMeta{
+		Name:        "u-john",
+		Annotations: map[string]string{"field.cattle.io/creatorId": "admin", RunIDAnnotation: "run-0"},
+	}}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	annotate(user, "activedirectory_user://CN=john,DC=example,DC=com", "run-1", now)
+
+	want := map[string]string{
+		"field.cattle.io/creatorId": "admin",
+		PreviousPrincipalAnnotation: "activedirectory_user://CN=john,DC=example,DC=com",
+		RunIDAnnotation:             "run-1",
+		UpdatedAtAnnotation:         "2024-05-01T10:00:00Z",
+	}
+	for key, value := range want {
+		if user.Annotations[key] != value {
+			t.Errorf("annotation %s = %q, want %q", key, user.Annotations[key], value)
+		}
+	}
+}
+
+func TestEmitUserEvent(t *testing.T) {
+	tests := []struct {
+		name        string
+		principalID string
+		updateErr   error
+		wantType    string
+		wantReason  string
+		wantMessage string
+	}{
+		{
+			name:        "migrated",
+			principalID: "activedirectory_user://CN=john,DC=example,DC=com",
+			wantType:    corev1.EventTypeNormal,
+			wantReason:  "PrincipalMigrated",
+			wantMessage: "Principal activedirectory_user://CN=john,DC=example,DC=com updated to activedirectory_user://objectGUID=00112233-4455-6677-8899-aabbccddeeff (run run-1)",
+		},
+		{
+			name:        "rollback failed",
+			principalID: "activedirectory_user://objectGUID=00112233-4455-6677-8899-aabbccddeeff",
+			updateErr:   errors.New("conflict"),
+			wantType:    corev1.EventTypeWarning,
+			wantReason:  "PrincipalRolledBackFailed",
+			wantMessage: "Cannot update principal activedirectory_user://objectGUID=00112233-4455-6677-8899-aabbccddeeff to activedirectory_user://CN=john,DC=example,DC=com (run run-1): conflict",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, c := newFakeRancher(t)
+
+			res := newTestUser(t, f, "u-john", "CN=john,DC=example,DC=com", "00112233-4455-6677-8899-aabbccddeeff")
+			res.PrincipalID = tt.principalID
+
+			emitUserEvent(context.Background(), c, res, "run-1", tt.updateErr)
+
+			names := f.names("events", eventsNamespace)
+			if len(names) != 1 || !strings.HasPrefix(names[0], "u-john.") {
+				t.Fatalf("events = %v, want one event of u-john", names)
+			}
+
+			event := &corev1.Event{}
+			f.get(t, "events", eventsNamespace, names[0], event)
+			if event.Type != tt.wantType || event.Reason != tt.wantReason || event.Message != tt.wantMessage {
+				t.Errorf("event = %s %s %q, want %s %s %q", event.Type, event.Reason, event.Message, tt.wantType, tt.wantReason, tt.wantMessage)
+			}
+			if event.InvolvedObject.Kind != "User" || event.InvolvedObject.Name != "u-john" || event.Annotations[RunIDAnnotation] != "run-1" {
+				t.Errorf("event = %+v %v, want involving the user u-john of run-1", event.InvolvedObject, event.Annotations)
+			}
+		})
+	}
+
+	// the principals without a user have no events
+	f, c := newFakeRancher(t)
+	emitUserEvent(context.Background(), c, &MigratableResource{PrincipalID: "activedirectory_user://CN=jane,DC=example,DC=com"}, "run-1", nil)
+	if len(f.requests) != 0 {
+		t.Errorf("requests = %v, want none", f.requests)
+	}
+}
@@ -0,0 +1,223 @@
+package version_1_10_0
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Identity is an Active Directory user with all the forms of its principal ID
+type Identity struct {
+	DN    string
+	GUID  guid.GUID
+	Match Match
+	// PrincipalIDs are the principal IDs of the user in the DN and in the objectGUID format
+	PrincipalIDs []string
+}
+
+// Inspect prints the Rancher user and all the resources referencing any of the principal IDs of an identity.
+// The identity can be a principal ID, a DN, an objectGUID, a Rancher username or an Active Directory account name.
+func (m *Migration) Inspect(ctx context.Context, identity string) error {
+	users, err := GetUsersToMigrate(ctx, m.client)
+	if err != nil {
+		return err
+	}
+
+	resolver, err := m.getResolver(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := resolveIdentity(resolver, identity, users)
+	if err != nil {
+		return err
+	}
+
+	bindings, err := GetUserBindings(ctx, m.client)
+	if err != nil {
+		return err
+	}
+
+	logging.Printf("Identity %s\n", blue(identity))
+	logging.Printf("\tDN:\t%s\n", valueOrNotFound(id.DN))
+	if id.GUID != nil {
+		logging.Printf("\tGUID:\t%s\n", green(id.GUID.UUID()))
+	} else {
+		logging.Printf("\tGUID:\t%s\n", red("not found"))
+	}
+	if id.Match.DN != "" {
+		logging.Printf("\tDomain:\t%s (%s)\n", id.Match.Domain, id.Match.Source)
+		if !id.Match.Exact() {
+			logging.Printf("\tMatch:\t%s (DN not found, matched by %s)\n", yellow("fallback"), id.Match.Attribute)
+		}
+	}
+
+	logging.Println("\n# Principal IDs")
+	var userNames []string
+	for _, principalID := range id.PrincipalIDs {
+		user, found := users[principalID]
+		binds := bindings[principalID]
+
+		logging.Printf("- %s\n", blue(principalID))
+		if !found && len(binds) == 0 {
+			logging.Println("\tNo resources")
+			continue
+		}
+
+		res := &MigratableResource{PrincipalID: principalID, User: user, Bindings: binds}
+		printResourceDetails(logging.Output, res)
+
+		if user != nil && !slices.Contains(userNames, user.Name) {
+			userNames = append(userNames, user.Name)
+		}
+	}
+
+	for _, userName := range userNames {
+		err = m.printUserReferences(ctx, userName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveIdentity finds the DN and the objectGUID of an identity
+func resolveIdentity(resolver Resolver, identity string, users map[string]*apiv3.User) (*Identity, error) {
+	id := &Identity{}
+	objectGUIDPrincipalPrefix := fmt.Sprintf("%s://%s=", ad.UserScope, ad.ObjectGUIDAttribute)
+
+	value := strings.TrimPrefix(identity, ad.UserScope+"://")
+	value = strings.TrimPrefix(value, ad.ObjectGUIDAttribute+"=")
+
+	var err error
+
+	switch {
+	case isGUID(value):
+		id.GUID, _ = guid.Parse(value)
+		id.Match, err = resolver.GetDN(id.GUID)
+		id.DN = id.Match.DN
+
+	case isDN(value):
+		id.DN = value
+		id.GUID, id.Match, err = resolver.GetGUID(value)
+
+	default:
+		principalID := rancherUserPrincipal(value, users)
+		if principalID != "" {
+			return resolveIdentity(resolver, principalID, users)
+		}
+		id.GUID, id.Match, err = resolver.GetGUIDByAccountName(value)
+		id.DN = id.Match.DN
+	}
+
+	if err != nil && !errors.Is(err, errUserNotFound) {
+		return nil, err
+	}
+	if err != nil && id.DN == "" && id.GUID == nil {
+		return nil, fmt.Errorf("identity '%s' not found: %w", identity, err)
+	}
+
+	if id.DN != "" {
+		id.PrincipalIDs = append(id.PrincipalIDs, ad.UserScope+"://"+id.DN)
+	}
+	if id.Match.DN != "" && !strings.EqualFold(id.Match.DN, id.DN) {
+		id.PrincipalIDs = append(id.PrincipalIDs, ad.UserScope+"://"+id.Match.DN)
+	}
+	if id.GUID != nil {
+		id.PrincipalIDs = append(id.PrincipalIDs, objectGUIDPrincipalPrefix+id.GUID.UUID())
+	}
+
+	return id, nil
+}
+
+// printUserReferences prints the GlobalRoleBindings and the UserAttribute of a Rancher user
+func (m *Migration) printUserReferences(ctx context.Context, userName string) error {
+	grbs := &apiv3.GlobalRoleBindingList{}
+	err := m.client.Rancher.Get().Resource("globalrolebindings").Do(ctx).Into(grbs)
+	if err != nil {
+		return err
+	}
+
+	logging.Printf("\n# User %s\n", yellow(userName))
+
+	var userGRBs []apiv3.GlobalRoleBinding
+	for _, grb := range grbs.Items {
+		if grb.UserName == userName {
+			userGRBs = append(userGRBs, grb)
+		}
+	}
+
+	logging.Printf("\tGlobalRoleBindings (%d)\n", len(userGRBs))
+	for _, grb := range userGRBs {
+		logging.Printf("\t- Name: %s, GlobalRole: %s\n", yellow(grb.Name), yellow(grb.GlobalRoleName))
+	}
+
+	userAttribute := &apiv3.UserAttribute{}
+	err = m.client.Rancher.Get().Resource("userattributes").Name(userName).Do(ctx).Into(userAttribute)
+	if apierrors.IsNotFound(err) {
+		logging.Println("\tUserAttribute not found")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	logging.Printf("\tUserAttribute %s\n", yellow(userAttribute.Name))
+	if userAttribute.LastLogin != nil {
+		logging.Printf("\t- LastLogin: %s\n", userAttribute.LastLogin.UTC())
+	}
+	for provider, principals := range userAttribute.GroupPrincipals {
+		logging.Printf("\t- GroupPrincipals (%s): %d\n", provider, len(principals.Items))
+		for _, principal := range principals.Items {
+			logging.Printf("\t\t%s\n", principal.Name)
+		}
+	}
+	for provider, extra := range userAttribute.ExtraByProvider {
+		for key, values := range extra {
+			logging.Printf("\t- Extra (%s) %s: %s\n", provider, key, strings.Join(values, ", "))
+		}
+	}
+
+	return nil
+}
+
+// rancherUserPrincipal returns the Active Directory principal ID of the Rancher user
+// with the provided name or username, or an empty string if not found
+func rancherUserPrincipal(name string, users map[string]*apiv3.User) string {
+	for principalID, user := range users {
+		if user.Name == name || (user.Username != "" && user.Username == name) {
+			return principalID
+		}
+	}
+	return ""
+}
+
+func isGUID(value string) bool {
+	_, err := guid.Parse(value)
+	return err == nil
+}
+
+func isDN(value string) bool {
+	if !strings.Contains(value, "=") {
+		return false
+	}
+	_, err := ldapv3.ParseDN(value)
+	return err == nil
+}
+
+func valueOrNotFound(value string) string {
+	if value == "" {
+		return red("not found")
+	}
+	return green(value)
+}
@@ -0,0 +1,119 @@
+package version_1_10_0
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResolveIdentity(t *testing.T) {
+	johnGUID, err := guid.Parse("00112233-4455-6677-8899-aabbccddeeff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver := NewDirectoryResolver("export.ldif", []DirectoryEntry{{
+		DN:             "CN=John Doe,OU=Moved,DC=example,DC=com",
+		GUID:           johnGUID,
+		SAMAccountName: "john",
+		AccountNames:   []string{"john", "john@example.com"},
+	}})
+
+	users := map[string]*apiv3.User{
+		"activedirectory_user://CN=john,OU=Users,DC=example,DC=com": {
+			ObjectMeta: metav1.ObjectMeta{Name: "u-john"},
+			Username:   "jdoe",
+		},
+	}
+
+	const (
+		currentDN   = "activedirectory_user://CN=John Doe,OU=Moved,DC=example,DC=com"
+		previousDN  = "activedirectory_user://CN=john,OU=Users,DC=example,DC=com"
+		objectGUIDs = "activedirectory_user://objectGUID=00112233-4455-6677-8899-aabbccddeeff"
+	)
+
+	tests := []struct {
+		name         string
+		identity     string
+		wantDN       string
+		wantExact    bool
+		wantIDs      []string
+		wantNotFound bool
+	}{
+		{
+			name:      "principal ID with the current DN",
+			identity:  currentDN,
+			wantDN:    "CN=John Doe,OU=Moved,DC=example,DC=com",
+			wantExact: true,
+			wantIDs:   []string{currentDN, objectGUIDs},
+		},
+		{
+			name:     "previous DN matched by account name",
+			identity: "CN=john,OU=Users,DC=example,DC=com",
+			wantDN:   "CN=john,OU=Users,DC=example,DC=com",
+			wantIDs:  []string{previousDN, currentDN, objectGUIDs},
+		},
+		{
+			name:      "objectGUID principal ID",
+			identity:  objectGUIDs,
+			wantDN:    "CN=John Doe,OU=Moved,DC=example,DC=com",
+			wantExact: true,
+			wantIDs:   []string{currentDN, objectGUIDs},
+		},
+		{
+			name:     "Rancher user name",
+			identity: "u-john",
+			wantDN:   "CN=john,OU=Users,DC=example,DC=com",
+			wantIDs:  []string{previousDN, currentDN, objectGUIDs},
+		},
+		{
+			name:     "Rancher username",
+			identity: "jdoe",
+			wantDN:   "CN=john,OU=Users,DC=example,DC=com",
+			wantIDs:  []string{previousDN, currentDN, objectGUIDs},
+		},
+		{
+			name:     "account name",
+			identity: "john@example.com",
+			wantDN:   "CN=John Doe,OU=Moved,DC=example,DC=com",
+			wantIDs:  []string{currentDN, objectGUIDs},
+		},
+		{
+			name:         "not found",
+			identity:     "jane",
+			wantNotFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := resolveIdentity(resolver, tt.identity, users)
+			if tt.wantNotFound {
+				if !errors.Is(err, errUserNotFound) {
+					t.Errorf("resolveIdentity() error = %v, want %v", err, errUserNotFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveIdentity() error = %v", err)
+			}
+
+			if id.DN != tt.wantDN {
+				t.Errorf("resolveIdentity() DN = %s, want %s", id.DN, tt.wantDN)
+			}
+			if id.GUID.UUID() != johnGUID.UUID() {
+				t.Errorf("resolveIdentity() GUID = %s, want %s", id.GUID.UUID(), johnGUID.UUID())
+			}
+			if id.Match.Exact() != tt.wantExact {
+				t.Errorf("resolveIdentity() exact match = %t, want %t", id.Match.Exact(), tt.wantExact)
+			}
+			if !reflect.DeepEqual(id.PrincipalIDs, tt.wantIDs) {
+				t.Errorf("resolveIdentity() principal IDs = %v, want %v", id.PrincipalIDs, tt.wantIDs)
+			}
+		})
+	}
+}
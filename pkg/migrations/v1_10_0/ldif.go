@@ -0,0 +1,130 @@
+package version_1_10_0
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+)
+
+// ReadLDIF returns the users of an LDIF export of Active Directory, i.e.:
+//
+//	ldapsearch -LLL -H ldaps://dc1.example.com -b DC=example,DC=com \
+//	  '(objectClass=person)' objectGUID sAMAccountName userPrincipalName memberOf > users.ldif
+//
+// The entries without an objectGUID are skipped.
+func ReadLDIF(path string) ([]DirectoryEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open LDIF file: %w", err)
+	}
+	defer f.Close()
+
+	var (
+		entries []DirectoryEntry
+		lines   []string
+		skipped int
+	)
+
+	// flush parses the lines of the current entry
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+
+		entry, err := parseLDIFEntry(lines)
+		lines = nil
+		if err != nil {
+			return fmt.Errorf("invalid LDIF file '%s': %w", path, err)
+		}
+
+		if entry.DN == "" || entry.GUID == nil {
+			skipped++
+			return nil
+		}
+		entries = append(entries, entry)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, " ") && len(lines) > 0:
+			// folded line, continuing the previous one
+			lines[len(lines)-1] += line[1:]
+		default:
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read LDIF file '%s': %w", path, err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	slog.Debug("LDIF file read", "path", path, "entries", len(entries), "skipped", skipped)
+
+	return entries, nil
+}
+
+// parseLDIFEntry returns the DirectoryEntry of the "attribute: value" or "attribute:: base64" lines of an entry
+func parseLDIFEntry(lines []string) (DirectoryEntry, error) {
+	entry := DirectoryEntry{}
+
+	for _, line := range lines {
+		attr, value, found := strings.Cut(line, ":")
+		if !found {
+			return entry, fmt.Errorf("invalid line '%s'", line)
+		}
+
+		isBase64 := strings.HasPrefix(value, ":")
+		value = strings.TrimSpace(strings.TrimPrefix(value, ":"))
+
+		var raw []byte
+		if isBase64 {
+			var err error
+			raw, err = base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return entry, fmt.Errorf("invalid base64 value of '%s': %w", attr, err)
+			}
+			value = string(raw)
+		}
+
+		switch strings.ToLower(attr) {
+		case "dn":
+			entry.DN = value
+		case "objectguid":
+			objectGUID, err := guid.New(raw)
+			if !isBase64 {
+				objectGUID, _, err = ParseGUID(value)
+			}
+			if err != nil {
+				return entry, fmt.Errorf("invalid objectGUID of '%s': %w", entry.DN, err)
+			}
+			entry.GUID = objectGUID
+		case "samaccountname":
+			entry.SAMAccountName = value
+			entry.AccountNames = append(entry.AccountNames, value)
+		case "userprincipalname":
+			entry.AccountNames = append(entry.AccountNames, value)
+		case "memberof":
+			entry.MemberOf = append(entry.MemberOf, value)
+		}
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,127 @@
+package version_1_10_0
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReadLDIF(t *testing.T) {
+	const uuid = "00112233-4455-6677-8899-aabbccddeeff"
+
+	tests := []struct {
+		name    string
+		ldif    string
+		want    []DirectoryEntry
+		wantErr bool
+	}{
+		{
+			name: "plain entry",
+			ldif: "dn: CN=John Doe,OU=Users,DC=example,DC=com\n" +
+				"objectGUID:: MyIRAFVEd2aImaq7zN3u/w==\n" +
+				"sAMAccountName: jdoe\n" +
+				"userPrincipalName: jdoe@example.com\n" +
+				"memberOf: CN=Admins,DC=example,DC=com\n",
+			want: []DirectoryEntry{{
+				DN:             "CN=John Doe,OU=Users,DC=example,DC=com",
+				SAMAccountName: "jdoe",
+				AccountNames:   []string{"jdoe", "jdoe@example.com"},
+				MemberOf:       []string{"CN=Admins,DC=example,DC=com"},
+			}},
+		},
+		{
+			name: "folded lines",
+			ldif: "dn: CN=John Doe,OU=Users,\n" +
+				" DC=example,DC=com\n" +
+				"objectGUID:: MyIRAFVEd2aI\n" +
+				" maq7zN3u/w==\n",
+			want: []DirectoryEntry{{DN: "CN=John Doe,OU=Users,DC=example,DC=com"}},
+		},
+		{
+			name: "base64 DN",
+			// "CN=Jöhn Doe,DC=example,DC=com"
+			ldif: "dn:: Q049SsO2aG4gRG9lLERDPWV4YW1wbGUsREM9Y29t\n" +
+				"objectGUID:: MyIRAFVEd2aImaq7zN3u/w==\n",
+			want: []DirectoryEntry{{DN: "CN=Jöhn Doe,DC=example,DC=com"}},
+		},
+		{
+			name: "objectGUID not in base64",
+			ldif: "dn: CN=John Doe,DC=example,DC=com\n" +
+				"objectGUID: " + uuid + "\n",
+			want: []DirectoryEntry{{DN: "CN=John Doe,DC=example,DC=com"}},
+		},
+		{
+			name: "comments, CRLF and entries without objectGUID",
+			ldif: "# users\r\n" +
+				"dn: CN=John Doe,DC=example,DC=com\r\n" +
+				"objectGUID:: MyIRAFVEd2aImaq7zN3u/w==\r\n" +
+				"\r\n" +
+				"dn: CN=Jane Doe,DC=example,DC=com\r\n" +
+				"sAMAccountName: jane\r\n" +
+				"\r\n" +
+				"# refldap://example.com/DC=example,DC=com\r\n",
+			want: []DirectoryEntry{{DN: "CN=John Doe,DC=example,DC=com"}},
+		},
+		{
+			name:    "invalid base64",
+			ldif:    "dn: CN=John Doe,DC=example,DC=com\nobjectGUID:: not base64!\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid objectGUID",
+			ldif:    "dn: CN=John Doe,DC=example,DC=com\nobjectGUID:: MyIRAA==\n",
+			wantErr: true,
+		},
+		{
+			name:    "line without attribute",
+			ldif:    "dn: CN=John Doe,DC=example,DC=com\nnot an attribute\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "users.ldif")
+			err := os.WriteFile(path, []byte(tt.ldif), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := ReadLDIF(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ReadLDIF() expected error, got %d entries", len(entries))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadLDIF() unexpected error: %v", err)
+			}
+
+			if len(entries) != len(tt.want) {
+				t.Fatalf("ReadLDIF() returned %d entries, want %d", len(entries), len(tt.want))
+			}
+
+			for i, want := range tt.want {
+				got := entries[i]
+
+				if got.DN != want.DN {
+					t.Errorf("entry %d: DN = %q, want %q", i, got.DN, want.DN)
+				}
+				if got.GUID.UUID() != uuid {
+					t.Errorf("entry %d: objectGUID = %s, want %s", i, got.GUID.UUID(), uuid)
+				}
+				if got.SAMAccountName != want.SAMAccountName {
+					t.Errorf("entry %d: sAMAccountName = %q, want %q", i, got.SAMAccountName, want.SAMAccountName)
+				}
+				if !slices.Equal(got.AccountNames, want.AccountNames) {
+					t.Errorf("entry %d: account names = %v, want %v", i, got.AccountNames, want.AccountNames)
+				}
+				if !slices.Equal(got.MemberOf, want.MemberOf) {
+					t.Errorf("entry %d: memberOf = %v, want %v", i, got.MemberOf, want.MemberOf)
+				}
+			}
+		})
+	}
+}
@@ -4,15 +4,19 @@ package version_1_10_0
 import (
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
 	"github.com/fatih/color"
-	ldapv3 "github.com/go-ldap/ldap/v3"
 	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
 	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
-	"github.com/rancher/rancher/pkg/auth/providers/common/ldap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 var (
@@ -22,132 +26,118 @@ var (
 	yellow = color.New(color.FgYellow).SprintFunc()
 )
 
-func Check(c *client.RancherClient, lConn *client.LdapClient, config *apiv3.ActiveDirectoryConfig) error {
-	fmt.Println("Checking resources")
+// Print writes the report of the resources with DNs and with GUIDs
+func (u MigratableResources) Print() {
+	dnResources := u.WithDNs()
+	guidResources := u.WithGUIDs()
 
-	migratable, err := GetMigratableResources(c, lConn.Conn, config)
-	if err != nil {
-		return err
-	}
-
-	dnResources := migratable.WithDNs()
-	guidResources := migratable.WithGUIDs()
-
-	fmt.Printf(
+	logging.Printf(
 		"Found %d resource groups that can be moved (%d containing DNs and %d containing objectGUIDs).\n\n",
-		len(migratable), len(dnResources), len(guidResources),
+		len(u), len(dnResources), len(guidResources),
 	)
 
-	fmt.Println("# Resources with DNs")
+	logging.Println("# Resources with DNs")
 	for i, res := range dnResources {
-		fmt.Printf("%00d) %s\n", i+1, blue(res.PrincipalID))
-		fmt.Printf("\tGUID:\t%s\n", green(res.GUID.UUID()))
-
-		if res.User == nil {
-			fmt.Println("\tUser not found")
-		} else {
-			fmt.Printf("\tUser %s (%s)\n", yellow(res.User.Name), blue(res.User.DisplayName))
-		}
-
-		prtbs := GetResourceByType[*PRTBResource](res.Bindings)
-		fmt.Printf("\tProjectRoleTemplateBindings (%d)\n", len(prtbs))
-		for _, prtb := range prtbs {
-			fmt.Printf("\t- Namespace: %s, Name: %s\n", yellow(prtb.PRTB.Namespace), yellow(prtb.PRTB.Name))
+		logging.Printf("%00d) %s\n", i+1, blue(res.PrincipalID))
+		logging.Printf("\tGUID:\t%s\n", green(res.GUID.UUID()))
+		if !res.Match.Exact() {
+			logging.Printf("\tMatch:\t%s (DN not found, matched by %s)\n", yellow("fallback"), res.Match.Attribute)
+			logging.Printf("\tNew DN:\t%s\n", yellow(res.Match.DN))
 		}
+		logging.Printf("\tDomain:\t%s (%s)\n", res.Match.Domain, res.Match.Source)
+		printPolicy(logging.Output, res)
 
-		crtbs := GetResourceByType[*CRTBResource](res.Bindings)
-		fmt.Printf("\tClusterRoleTemplateBindings (%d)\n", len(crtbs))
-		for _, crtb := range crtbs {
-			fmt.Printf("\t- Namespace: %s, Name: %s\n", yellow(crtb.CRTB.Namespace), yellow(crtb.CRTB.Name))
-		}
-
-		tokens := GetResourceByType[*TokenResource](res.Bindings)
-		fmt.Printf("\tTokens (%d)\n", len(tokens))
-		for _, token := range tokens {
-			fmt.Printf("\t- Namespace: %s, Name: %s\n", yellow(token.Token.Namespace), yellow(token.Token.Name))
-		}
+		logResource(res)
+		printResourceDetails(logging.Output, res)
 	}
 
-	fmt.Println("\n# Resources with GUIDs")
+	logging.Println("\n# Resources with GUIDs")
 	for i, res := range guidResources {
-		fmt.Printf("%00d) %s\n", i+1, blue(res.PrincipalID))
-		fmt.Printf("\tDN:\t%s\n", green(res.DN))
+		logging.Printf("%00d) %s\n", i+1, blue(res.PrincipalID))
+		logging.Printf("\tDN:\t%s\n", green(res.DN))
+		logging.Printf("\tDomain:\t%s (%s)\n", res.Match.Domain, res.Match.Source)
+		printPolicy(logging.Output, res)
 
-		if res.User == nil {
-			fmt.Println("\tUser not found")
-		} else {
-			fmt.Printf("\tUser %s (%s)\n", yellow(res.User.Name), blue(res.User.DisplayName))
-		}
+		logResource(res)
+		printResourceDetails(logging.Output, res)
+	}
+}
 
-		prtbs := GetResourceByType[*PRTBResource](res.Bindings)
-		fmt.Printf("\tProjectRoleTemplateBindings (%d)\n", len(prtbs))
-		for _, prtb := range prtbs {
-			fmt.Printf("\t- Namespace: %s, Name: %s\n", yellow(prtb.PRTB.Namespace), yellow(prtb.PRTB.Name))
-		}
+// printResourceDetails prints the user and the bindings of a MigratableResource
+func printResourceDetails(out io.Writer, res *MigratableResource) {
+	if res.User == nil {
+		fmt.Fprintln(out, "\tUser not found")
+	} else {
+		fmt.Fprintf(out, "\tUser %s (%s)\n", yellow(res.User.Name), blue(res.User.DisplayName))
+	}
 
-		crtbs := GetResourceByType[*CRTBResource](res.Bindings)
-		fmt.Printf("\tClusterRoleTemplateBindings (%d)\n", len(crtbs))
-		for _, crtb := range crtbs {
-			fmt.Printf("\t- Namespace: %s, Name: %s\n", yellow(crtb.CRTB.Namespace), yellow(crtb.CRTB.Name))
-		}
+	prtbs := GetResourceByType[*PRTBResource](res.Bindings)
+	fmt.Fprintf(out, "\tProjectRoleTemplateBindings (%d)\n", len(prtbs))
+	for _, prtb := range prtbs {
+		fmt.Fprintf(out, "\t- Namespace: %s, Name: %s\n", yellow(prtb.PRTB.Namespace), yellow(prtb.PRTB.Name))
+	}
 
-		tokens := GetResourceByType[*TokenResource](res.Bindings)
-		fmt.Printf("\tTokens (%d)\n", len(tokens))
-		for _, token := range tokens {
-			fmt.Printf("\t- Name: %s\n", yellow(token.Token.Name))
-		}
+	crtbs := GetResourceByType[*CRTBResource](res.Bindings)
+	fmt.Fprintf(out, "\tClusterRoleTemplateBindings (%d)\n", len(crtbs))
+	for _, crtb := range crtbs {
+		fmt.Fprintf(out, "\t- Namespace: %s, Name: %s\n", yellow(crtb.CRTB.Namespace), yellow(crtb.CRTB.Name))
 	}
 
-	return nil
+	tokens := GetResourceByType[*TokenResource](res.Bindings)
+	fmt.Fprintf(out, "\tTokens (%d)\n", len(tokens))
+	for _, token := range tokens {
+		fmt.Fprintf(out, "\t- Name: %s\n", yellow(token.Token.Name))
+	}
 }
 
-func Migrate(c *client.RancherClient, lConn *client.LdapClient, config *apiv3.ActiveDirectoryConfig, principalIDs []string) error {
-	fmt.Println("Start migration...")
+// updateResources updates the resources, saving the pending principals in the StateFile
+// if the update is interrupted or fails
+func updateResources(ctx context.Context, c *client.RancherClient, operation string, resources []*MigratableResource, opts migrations.ApplyOptions) error {
+	if opts.Phased() {
+		return updateWaves(ctx, c, operation, resources, opts)
+	}
 
-	migratable, err := GetMigratableResources(c, lConn.Conn, config)
-	if err != nil {
+	updated, err := UpdateResources(ctx, c, resources, opts)
+	if err == nil || opts.StateFile == "" {
 		return err
 	}
 
-	if len(principalIDs) > 0 {
-		filtered := MigratableResources{}
-		for _, pID := range principalIDs {
-			filtered[pID] = migratable[pID]
-		}
-		migratable = filtered
+	state := migrations.NewState(operation, principalIDs(resources), updated, err)
+
+	saveErr := state.Save(opts.StateFile)
+	if saveErr != nil {
+		return fmt.Errorf("%w (cannot save state: %s)", err, saveErr)
 	}
 
-	dnResources := migratable.WithDNs()
+	slog.Warn("state saved", "operation", operation, "pending", len(state.Pending), "stateFile", opts.StateFile)
+	logging.Printf(
+		"%d principals pending, state saved in '%s': run the %s again with --resume to continue\n",
+		len(state.Pending), opts.StateFile, operation,
+	)
 
-	return UpdateResources(c, dnResources)
+	return err
 }
 
-func Rollback(c *client.RancherClient, lConn *client.LdapClient, config *apiv3.ActiveDirectoryConfig, principalIDs []string) error {
-	fmt.Println("Start rollback")
-
-	migratable, err := GetMigratableResources(c, lConn.Conn, config)
+// GetMigratableResources will return a map of resources that can be migrated or rolled back
+func GetMigratableResources(ctx context.Context, c *client.RancherClient, resolver Resolver) (MigratableResources, error) {
+	resourcesToMigrate, err := ListMigratableResources(ctx, c)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if len(principalIDs) > 0 {
-		filtered := MigratableResources{}
-		for _, pID := range principalIDs {
-			filtered[pID] = migratable[pID]
-		}
-		migratable = filtered
+	err = resourcesToMigrate.Resolve(resolver)
+	if err != nil {
+		return nil, err
 	}
 
-	guidResources := migratable.WithGUIDs()
-
-	return UpdateResources(c, guidResources)
+	return resourcesToMigrate, nil
 }
 
-// GetMigratableResources will return a map of resources that can be migrated or rolled back
-func GetMigratableResources(c *client.RancherClient, lConn *ldapv3.Conn, config *apiv3.ActiveDirectoryConfig) (MigratableResources, error) {
+// ListMigratableResources will return the users and the bindings of every principal, without resolving them
+func ListMigratableResources(ctx context.Context, c *client.RancherClient) (MigratableResources, error) {
 	resourcesToMigrate := map[string]*MigratableResource{}
 
-	userMap, err := GetUsersToMigrate(c)
+	userMap, err := GetUsersToMigrate(ctx, c)
 	if err != nil {
 		return nil, err
 	}
@@ -159,7 +149,7 @@ func GetMigratableResources(c *client.RancherClient, lConn *ldapv3.Conn, config
 		}
 	}
 
-	bindingsMap, err := GetUserBindings(c)
+	bindingsMap, err := GetUserBindings(ctx, c)
 	if err != nil {
 		return nil, err
 	}
@@ -177,8 +167,14 @@ func GetMigratableResources(c *client.RancherClient, lConn *ldapv3.Conn, config
 		resourcesToMigrate[principalID] = res
 	}
 
-	// fill DN and/or GUID
-	for principalID, res := range resourcesToMigrate {
+	return resourcesToMigrate, nil
+}
+
+// Resolve fills the DN and the GUID of every principal
+func (u MigratableResources) Resolve(resolver Resolver) error {
+	var err error
+
+	for principalID, res := range u {
 		objectGUIDPrincipalPrefix := fmt.Sprintf("%s://%s=", ad.UserScope, ad.ObjectGUIDAttribute)
 
 		if strings.HasPrefix(principalID, objectGUIDPrincipalPrefix) {
@@ -186,31 +182,41 @@ func GetMigratableResources(c *client.RancherClient, lConn *ldapv3.Conn, config
 
 			parsedGUID, err := guid.Parse(objectGUID)
 			if err != nil {
-				return nil, err
+				return err
 			}
 
 			res.GUID = parsedGUID
-			res.DN, err = getDN(lConn, config, parsedGUID)
+			res.Match, err = resolver.GetDN(parsedGUID)
 			if err != nil {
-				return nil, err
+				return err
 			}
+			res.DN = res.Match.DN
 
 		} else {
 			res.DN = strings.TrimPrefix(principalID, ad.UserScope+"://")
-			res.GUID, err = getGUID(lConn, config, res.DN)
+			res.GUID, res.Match, err = resolver.GetGUID(res.DN)
 			if err != nil {
-				return nil, err
+				return err
 			}
 		}
+
+		slog.Debug("principal resolved",
+			"principal", principalID,
+			"dn", res.Match.DN,
+			"guid", res.GUID.UUID(),
+			"domain", res.Match.Domain,
+			"source", res.Match.Source,
+			"matchedBy", res.Match.Attribute,
+		)
 	}
 
-	return resourcesToMigrate, nil
+	return nil
 }
 
 // GetUsersToMigrate will fetch all the users with an old activedirectory PrincipalID
-func GetUsersToMigrate(c *client.RancherClient) (map[string]*apiv3.User, error) {
+func GetUsersToMigrate(ctx context.Context, c *client.RancherClient) (map[string]*apiv3.User, error) {
 	users := &apiv3.UserList{}
-	err := c.Rancher.Get().Resource("users").Do(context.Background()).Into(users)
+	err := c.Rancher.Get().Resource("users").Do(ctx).Into(users)
 	if err != nil {
 		return nil, err
 	}
@@ -228,11 +234,11 @@ func GetUsersToMigrate(c *client.RancherClient) (map[string]*apiv3.User, error)
 	return migratableUsers, nil
 }
 
-func GetUserBindings(c *client.RancherClient) (map[string][]PrincipalIDResource, error) {
+func GetUserBindings(ctx context.Context, c *client.RancherClient) (map[string][]PrincipalIDResource, error) {
 	userBindings := map[string][]PrincipalIDResource{}
 
 	prtbs := &apiv3.ProjectRoleTemplateBindingList{}
-	err := c.Rancher.Get().Resource("projectroletemplatebindings").Do(context.Background()).Into(prtbs)
+	err := c.Rancher.Get().Resource("projectroletemplatebindings").Do(ctx).Into(prtbs)
 	if err != nil {
 		return nil, err
 	}
@@ -251,7 +257,7 @@ func GetUserBindings(c *client.RancherClient) (map[string][]PrincipalIDResource,
 	}
 
 	crtbs := &apiv3.ClusterRoleTemplateBindingList{}
-	err = c.Rancher.Get().Resource("clusterroletemplatebindings").Do(context.Background()).Into(crtbs)
+	err = c.Rancher.Get().Resource("clusterroletemplatebindings").Do(ctx).Into(crtbs)
 	if err != nil {
 		return nil, err
 	}
@@ -270,7 +276,7 @@ func GetUserBindings(c *client.RancherClient) (map[string][]PrincipalIDResource,
 	}
 
 	tokens := &apiv3.TokenList{}
-	err = c.Rancher.Get().Resource("tokens").Do(context.Background()).Into(tokens)
+	err = c.Rancher.Get().Resource("tokens").Do(ctx).Into(tokens)
 	if err != nil {
 		return nil, err
 	}
@@ -291,121 +297,131 @@ func GetUserBindings(c *client.RancherClient) (map[string][]PrincipalIDResource,
 	return userBindings, nil
 }
 
-func getGUID(lConn *ldapv3.Conn, config *apiv3.ActiveDirectoryConfig, dn string) (guid.GUID, error) {
-	search := ldap.NewBaseObjectSearchRequest(
-		dn,
-		fmt.Sprintf("(%v=%v)", ad.ObjectClass, config.UserObjectClass),
-		config.GetUserSearchAttributes(ad.MemberOfAttribute, ad.ObjectClass, "objectGUID"),
-	)
+// UpdateResources updates the resources of every principal, and returns the number of principals updated.
+// The update of a principal is not interrupted by the cancellation of the context, that will stop
+// the update only before the next principal.
+func UpdateResources(ctx context.Context, c *client.RancherClient, resources []*MigratableResource, opts migrations.ApplyOptions) (int, error) {
+	for i, res := range resources {
+		if err := ctx.Err(); err != nil {
+			return i, fmt.Errorf("update interrupted after %d/%d principals: %w", i, len(resources), err)
+		}
 
-	results, err := lConn.Search(search)
-	if err != nil {
-		return nil, fmt.Errorf("LDAP search of user by objectGUID failed: %w", err)
-	}
+		logging.Printf("--- (%02d/%02d) ---\n", i+1, len(resources))
 
-	objectGUID := results.Entries[0].GetRawAttributeValue("objectGUID")
-	parsedGuid, err := guid.New(objectGUID)
-	if err != nil {
-		return nil, fmt.Errorf("LDAP search of user by objectGUID failed: %w", err)
+		err := updateResourceStep(ctx, c, res, opts)
+		if err != nil {
+			return i, err
+		}
 	}
 
-	return parsedGuid, nil
+	return len(resources), nil
 }
 
-func getDN(lConn *ldapv3.Conn, config *apiv3.ActiveDirectoryConfig, uuid guid.GUID) (string, error) {
-	filter := fmt.Sprintf(
-		"(&(%v=%v)(%s=%s))",
-		ad.ObjectClass, config.UserObjectClass,
-		ad.ObjectGUIDAttribute, guid.Escape(uuid),
-	)
+// updateResourceStep updates a principal with a context that is not canceled with its parent,
+// so that an interrupt will not leave the resources of the principal partially updated.
+// The diffs of the objects are printed before the update, if enabled.
+func updateResourceStep(ctx context.Context, c *client.RancherClient, res *MigratableResource, opts migrations.ApplyOptions) error {
+	if opts.Diff {
+		err := printDiffs(res, opts.RunID)
+		if err != nil {
+			return err
+		}
+	}
 
-	search := ldap.NewWholeSubtreeSearchRequest(
-		config.UserSearchBase,
-		filter,
-		config.GetUserSearchAttributes(ad.MemberOfAttribute, ad.ObjectClass, "objectGUID"),
-	)
+	stepCtx := context.WithoutCancel(ctx)
 
-	results, err := lConn.Search(search)
-	if err != nil {
-		return "", fmt.Errorf("LDAP search of user by objectGUID failed: %w", err)
+	if opts.StepTimeout > 0 {
+		var cancel context.CancelFunc
+		stepCtx, cancel = context.WithTimeout(stepCtx, opts.StepTimeout)
+		defer cancel()
 	}
 
-	return results.Entries[0].DN, nil
+	err := UpdateResource(stepCtx, c, res, opts.RunID)
+	emitUserEvent(stepCtx, c, res, opts.RunID, err)
+	return err
 }
 
-func UpdateResources(c *client.RancherClient, resources []*MigratableResource) error {
+// UpdateResource updates the principal of the user and of all the resources of a MigratableResource.
+// The updated objects are annotated with the previous principal, the run ID and the time of the update.
+func UpdateResource(ctx context.Context, c *client.RancherClient, res *MigratableResource, runID string) error {
 	var err error
 
-	for i, res := range resources {
-		ctx := context.Background()
+	now := time.Now()
+	updatedPrincipalID := GetUpdatedPrincipalID(res)
+	logger := slog.With("principal", res.PrincipalID, "updatedPrincipal", updatedPrincipalID)
 
-		updatedPrincipalID := GetUpdatedPrincipalID(res)
-		fmt.Printf("--- (%02d/%02d) ---\n", i+1, len(resources))
-		fmt.Printf("Updating principal %s\nto %s\n", red(res.PrincipalID), green(updatedPrincipalID))
+	logging.Printf("Updating principal %s\nto %s\n", red(res.PrincipalID), green(updatedPrincipalID))
+	if !res.Match.Exact() {
+		logging.Printf("%s: DN not found, user matched by %s (%s)\n", yellow("fallback"), res.Match.Attribute, res.Match.DN)
+	}
 
-		// updating user principal
-		if res.User != nil {
-			fmt.Printf("- Updating user %s (%s) principal\n", blue(res.User.Name), blue(res.User.DisplayName))
+	// updating user principal
+	if res.User != nil {
+		logging.Printf("- Updating user %s (%s) principal\n", blue(res.User.Name), blue(res.User.DisplayName))
 
-			res.UpdatePrincipalID(updatedPrincipalID)
+		res.UpdatePrincipalID(updatedPrincipalID)
+		annotate(res.User, res.PrincipalID, runID, now)
 
-			result := c.Rancher.Put().Resource("users").Name(res.User.Name).Body(res.User).Do(ctx)
-			err = result.Error()
-			if err != nil {
-				return err
-			}
-
-			fmt.Println("User updated")
+		result := c.Rancher.Put().Resource("users").Name(res.User.Name).Body(res.User).Do(ctx)
+		err = result.Error()
+		logAction(logger, "update", "User", "", res.User.Name, err)
+		if err != nil {
+			return err
 		}
 
-		prtbs := GetResourceByType[*PRTBResource](res.Bindings)
-		if len(prtbs) == 0 {
-			fmt.Println("- No ProjectRoleTemplateBindings to update.")
-		} else {
-			fmt.Printf("- Updating %d ProjectRoleTemplateBindings\n", len(prtbs))
+		logging.Println("User updated")
+	}
 
-			for _, prtb := range prtbs {
-				// update PRTB
-				prtb.SetPrincipalName(updatedPrincipalID)
+	prtbs := GetResourceByType[*PRTBResource](res.Bindings)
+	if len(prtbs) == 0 {
+		logging.Println("- No ProjectRoleTemplateBindings to update.")
+	} else {
+		logging.Printf("- Updating %d ProjectRoleTemplateBindings\n", len(prtbs))
 
-				err = UpdatePRTB(ctx, c, prtb)
-				if err != nil {
-					return err
-				}
+		for _, prtb := range prtbs {
+			// update PRTB
+			prtb.SetPrincipalName(updatedPrincipalID)
+			annotate(prtb.PRTB, res.PrincipalID, runID, now)
+
+			err = UpdatePRTB(ctx, c, logger, prtb)
+			if err != nil {
+				return err
 			}
 		}
+	}
 
-		crtbs := GetResourceByType[*CRTBResource](res.Bindings)
-		if len(crtbs) == 0 {
-			fmt.Println("- No ClusterRoleTemplateBindings to update.")
-		} else {
-			fmt.Printf("- Updating %d ClusterRoleTemplateBindings\n", len(crtbs))
+	crtbs := GetResourceByType[*CRTBResource](res.Bindings)
+	if len(crtbs) == 0 {
+		logging.Println("- No ClusterRoleTemplateBindings to update.")
+	} else {
+		logging.Printf("- Updating %d ClusterRoleTemplateBindings\n", len(crtbs))
 
-			for _, crtb := range crtbs {
-				// update CRTB
-				crtb.SetPrincipalName(updatedPrincipalID)
+		for _, crtb := range crtbs {
+			// update CRTB
+			crtb.SetPrincipalName(updatedPrincipalID)
+			annotate(crtb.CRTB, res.PrincipalID, runID, now)
 
-				err = UpdateCRTB(ctx, c, crtb)
-				if err != nil {
-					return err
-				}
+			err = UpdateCRTB(ctx, c, logger, crtb)
+			if err != nil {
+				return err
 			}
 		}
+	}
 
-		tokens := GetResourceByType[*TokenResource](res.Bindings)
-		if len(tokens) == 0 {
-			fmt.Println("- No Tokens to update.")
-		} else {
-			fmt.Printf("- Updating %d Tokens\n", len(tokens))
+	tokens := GetResourceByType[*TokenResource](res.Bindings)
+	if len(tokens) == 0 {
+		logging.Println("- No Tokens to update.")
+	} else {
+		logging.Printf("- Updating %d Tokens\n", len(tokens))
 
-			for _, token := range tokens {
-				// update Token
-				token.SetPrincipalName(updatedPrincipalID)
+		for _, token := range tokens {
+			// update Token
+			token.SetPrincipalName(updatedPrincipalID)
+			annotate(token.Token, res.PrincipalID, runID, now)
 
-				err = UpdateToken(ctx, c, token)
-				if err != nil {
-					return err
-				}
+			err = UpdateToken(ctx, c, logger, token)
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -424,30 +440,47 @@ func GetUpdatedPrincipalID(u *MigratableResource) string {
 	return fmt.Sprintf("%s://%s", ad.UserScope, u.DN)
 }
 
-func UpdatePRTB(ctx context.Context, c *client.RancherClient, prtb *PRTBResource) error {
+// UpdatePRTB replaces the PRTB with a new one of the updated principal. A new PRTB already created
+// by an interrupted run is reused, and an old PRTB already deleted is not an error, so that the update can be retried.
+func UpdatePRTB(ctx context.Context, c *client.RancherClient, logger *slog.Logger, prtb *PRTBResource) error {
 	// generate a new PRTB
 	oldPRTBName := prtb.PRTB.Name
-	prtb.PRTB.Name = ""
-	prtb.PRTB.ResourceVersion = ""
 
-	fmt.Printf("Creating new ProjectRoleTemplateBinding in namespace %s\n", yellow(prtb.PRTB.Namespace))
-
-	newPRTB := &apiv3.ProjectRoleTemplateBinding{}
-	err := c.Rancher.Post().Resource("projectroletemplatebindings").
-		Namespace(prtb.PRTB.Namespace).
-		Body(prtb.PRTB).
-		Do(ctx).
-		Into(newPRTB)
+	newPRTB, err := findPRTB(ctx, c, prtb.PRTB)
 	if err != nil {
-		return fmt.Errorf("cannot create new ProjectRoleTemplateBinding: %w\n", err)
+		return err
 	}
 
-	fmt.Printf(
-		"- New ProjectRoleTemplateBinding '%s' in namespace '%s' created.\n",
-		green(newPRTB.Name), yellow(newPRTB.Namespace),
-	)
+	if newPRTB != nil {
+		logger.Info("binding already created", "kind", "ProjectRoleTemplateBinding", "namespace", newPRTB.Namespace, "name", newPRTB.Name)
+		logging.Printf(
+			"- New ProjectRoleTemplateBinding '%s' in namespace '%s' already created.\n",
+			green(newPRTB.Name), yellow(newPRTB.Namespace),
+		)
+	} else {
+		prtb.PRTB.Name = ""
+		prtb.PRTB.ResourceVersion = ""
+
+		logging.Printf("Creating new ProjectRoleTemplateBinding in namespace %s\n", yellow(prtb.PRTB.Namespace))
+
+		newPRTB = &apiv3.ProjectRoleTemplateBinding{}
+		err = c.Rancher.Post().Resource("projectroletemplatebindings").
+			Namespace(prtb.PRTB.Namespace).
+			Body(prtb.PRTB).
+			Do(ctx).
+			Into(newPRTB)
+		logAction(logger, "create", "ProjectRoleTemplateBinding", prtb.PRTB.Namespace, newPRTB.Name, err)
+		if err != nil {
+			return fmt.Errorf("cannot create new ProjectRoleTemplateBinding: %w\n", err)
+		}
+
+		logging.Printf(
+			"- New ProjectRoleTemplateBinding '%s' in namespace '%s' created.\n",
+			green(newPRTB.Name), yellow(newPRTB.Namespace),
+		)
+	}
 
-	fmt.Printf(
+	logging.Printf(
 		"Deleting old ProjectRoleTemplateBinding '%s' in namespace '%s'\n",
 		red(oldPRTBName), yellow(prtb.PRTB.Namespace),
 	)
@@ -456,49 +489,77 @@ func UpdatePRTB(ctx context.Context, c *client.RancherClient, prtb *PRTBResource
 		Namespace(prtb.PRTB.Namespace).
 		Do(ctx).
 		Error()
+	if apierrors.IsNotFound(err) {
+		// already deleted by an interrupted run
+		err = nil
+	}
+	logAction(logger, "delete", "ProjectRoleTemplateBinding", prtb.PRTB.Namespace, oldPRTBName, err)
 	if err != nil {
 		return fmt.Errorf("cannot delete old ProjectRoleTemplateBinding: %w", err)
 	}
 
-	fmt.Printf(
+	logging.Printf(
 		"- Old ProjectRoleTemplateBinding '%s' in namespace '%s' deleted\n",
 		red(oldPRTBName), yellow(prtb.PRTB.Namespace),
 	)
 	return nil
 }
 
-func UpdateCRTB(ctx context.Context, c *client.RancherClient, crtb *CRTBResource) error {
+// UpdateCRTB replaces the CRTB with a new one of the updated principal. A new CRTB already created
+// by an interrupted run is reused, and an old CRTB already deleted is not an error, so that the update can be retried.
+func UpdateCRTB(ctx context.Context, c *client.RancherClient, logger *slog.Logger, crtb *CRTBResource) error {
 	// generate a new CRTB
 	oldCRTBName := crtb.CRTB.Name
-	crtb.CRTB.Name = ""
-	crtb.CRTB.ResourceVersion = ""
-
-	fmt.Printf("Creating new ClusterRoleTemplateBinding in namespace %s\n", blue(crtb.CRTB.Namespace))
 
-	newCRTB := &apiv3.ClusterRoleTemplateBinding{}
-	err := c.Rancher.Post().Resource("clusterroletemplatebindings").
-		Namespace(crtb.CRTB.Namespace).
-		Body(crtb.CRTB).
-		Do(ctx).
-		Into(newCRTB)
+	newCRTB, err := findCRTB(ctx, c, crtb.CRTB)
 	if err != nil {
-		return fmt.Errorf(
-			"cannot create new ClusterRoleTemplateBinding in namespace '%s': %w\n",
-			crtb.CRTB.Namespace, err,
-		)
+		return err
 	}
 
-	fmt.Printf(
-		"New ClusterRoleTemplateBinding created (%s), deleting old one (%s)\n",
-		green(newCRTB.Name),
-		red(oldCRTBName),
-	)
+	if newCRTB != nil {
+		logger.Info("binding already created", "kind", "ClusterRoleTemplateBinding", "namespace", newCRTB.Namespace, "name", newCRTB.Name)
+		logging.Printf(
+			"New ClusterRoleTemplateBinding already created (%s), deleting old one (%s)\n",
+			green(newCRTB.Name),
+			red(oldCRTBName),
+		)
+	} else {
+		crtb.CRTB.Name = ""
+		crtb.CRTB.ResourceVersion = ""
+
+		logging.Printf("Creating new ClusterRoleTemplateBinding in namespace %s\n", blue(crtb.CRTB.Namespace))
+
+		newCRTB = &apiv3.ClusterRoleTemplateBinding{}
+		err = c.Rancher.Post().Resource("clusterroletemplatebindings").
+			Namespace(crtb.CRTB.Namespace).
+			Body(crtb.CRTB).
+			Do(ctx).
+			Into(newCRTB)
+		logAction(logger, "create", "ClusterRoleTemplateBinding", crtb.CRTB.Namespace, newCRTB.Name, err)
+		if err != nil {
+			return fmt.Errorf(
+				"cannot create new ClusterRoleTemplateBinding in namespace '%s': %w\n",
+				crtb.CRTB.Namespace, err,
+			)
+		}
+
+		logging.Printf(
+			"New ClusterRoleTemplateBinding created (%s), deleting old one (%s)\n",
+			green(newCRTB.Name),
+			red(oldCRTBName),
+		)
+	}
 
 	err = c.Rancher.Delete().Resource("clusterroletemplatebindings").
 		Name(oldCRTBName).
 		Namespace(crtb.CRTB.Namespace).
 		Do(ctx).
 		Error()
+	if apierrors.IsNotFound(err) {
+		// already deleted by an interrupted run
+		err = nil
+	}
+	logAction(logger, "delete", "ClusterRoleTemplateBinding", crtb.CRTB.Namespace, oldCRTBName, err)
 	if err != nil {
 		return fmt.Errorf(
 			"cannot delete old ClusterRoleTemplateBinding '%s' in namespace '%s': %w\n",
@@ -506,20 +567,99 @@ func UpdateCRTB(ctx context.Context, c *client.RancherClient, crtb *CRTBResource
 		)
 	}
 
-	fmt.Printf("Old ClusterRoleTemplateBinding deleted (%s)\n", red(oldCRTBName))
+	logging.Printf("Old ClusterRoleTemplateBinding deleted (%s)\n", red(oldCRTBName))
 	return nil
 }
 
-func UpdateToken(ctx context.Context, c *client.RancherClient, token *TokenResource) error {
+// findPRTB returns the PRTB, other than the given one, with its same role, project and principal, nil if not found
+func findPRTB(ctx context.Context, c *client.RancherClient, prtb *apiv3.ProjectRoleTemplateBinding) (*apiv3.ProjectRoleTemplateBinding, error) {
+	prtbs := &apiv3.ProjectRoleTemplateBindingList{}
+	err := c.Rancher.Get().Resource("projectroletemplatebindings").Namespace(prtb.Namespace).Do(ctx).Into(prtbs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list ProjectRoleTemplateBindings in namespace '%s': %w", prtb.Namespace, err)
+	}
+
+	for i := range prtbs.Items {
+		existing := &prtbs.Items[i]
+		if existing.Name != prtb.Name &&
+			existing.RoleTemplateName == prtb.RoleTemplateName &&
+			existing.ProjectName == prtb.ProjectName &&
+			existing.UserPrincipalName == prtb.UserPrincipalName {
+			return existing, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// findCRTB returns the CRTB, other than the given one, with its same role, cluster and principal, nil if not found
+func findCRTB(ctx context.Context, c *client.RancherClient, crtb *apiv3.ClusterRoleTemplateBinding) (*apiv3.ClusterRoleTemplateBinding, error) {
+	crtbs := &apiv3.ClusterRoleTemplateBindingList{}
+	err := c.Rancher.Get().Resource("clusterroletemplatebindings").Namespace(crtb.Namespace).Do(ctx).Into(crtbs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list ClusterRoleTemplateBindings in namespace '%s': %w", crtb.Namespace, err)
+	}
+
+	for i := range crtbs.Items {
+		existing := &crtbs.Items[i]
+		if existing.Name != crtb.Name &&
+			existing.RoleTemplateName == crtb.RoleTemplateName &&
+			existing.ClusterName == crtb.ClusterName &&
+			existing.UserPrincipalName == crtb.UserPrincipalName {
+			return existing, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func UpdateToken(ctx context.Context, c *client.RancherClient, logger *slog.Logger, token *TokenResource) error {
 	err := c.Rancher.Put().Resource("tokens").
 		Name(token.Token.Name).
 		Body(token.Token).
 		Do(ctx).
 		Error()
+	logAction(logger, "update", "Token", "", token.Token.Name, err)
 	if err != nil {
 		return fmt.Errorf("cannot update token '%s': %w\n", token.Token.Name, err)
 	}
 
-	fmt.Printf("Token updated (%s)\n", green(token.Token.Name))
+	logging.Printf("Token updated (%s)\n", green(token.Token.Name))
 	return nil
 }
+
+// logResource logs the principal and the number of resources referencing it
+func logResource(res *MigratableResource) {
+	var user string
+	if res.User != nil {
+		user = res.User.Name
+	}
+
+	slog.Info("principal found",
+		"principal", res.PrincipalID,
+		"dn", res.DN,
+		"guid", res.GUID.UUID(),
+		"domain", res.Match.Domain,
+		"matchedBy", res.Match.Attribute,
+		"user", user,
+		"prtbs", len(GetResourceByType[*PRTBResource](res.Bindings)),
+		"crtbs", len(GetResourceByType[*CRTBResource](res.Bindings)),
+		"tokens", len(GetResourceByType[*TokenResource](res.Bindings)),
+	)
+}
+
+// logAction logs the outcome of an action on a resource
+func logAction(logger *slog.Logger, action, kind, namespace, name string, err error) {
+	attrs := []any{
+		"action", action,
+		"kind", kind,
+		"namespace", namespace,
+		"name", name,
+	}
+
+	if err != nil {
+		logger.Error(action+" "+kind, append(attrs, "outcome", "failed", "error", err)...)
+		return
+	}
+	logger.Info(action+" "+kind, append(attrs, "outcome", "success")...)
+}
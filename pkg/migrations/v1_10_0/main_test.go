@@ -0,0 +1,377 @@
+package version_1_10_0
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+// fakeRancher is an in memory Rancher and Kubernetes API, serving the objects as JSON.
+// The updates with a stale resourceVersion fail with a conflict.
+type fakeRancher struct {
+	mu      sync.Mutex
+	objects map[string]map[string]any
+	version int
+
+	// requests are the served requests, as "<method> <resource>[/<namespace>]/<name>"
+	requests []string
+	// onRequest is called before serving every request, i.e. to cancel a context
+	onRequest func(method, resource, name string)
+}
+
+// newFakeRancher returns a fakeRancher and a client connected to it, discarding the human readable output
+func newFakeRancher(t *testing.T) (*fakeRancher, *client.RancherClient) {
+	t.Helper()
+
+	output := logging.Output
+	logging.Output = io.Discard
+	t.Cleanup(func() { logging.Output = output })
+
+	f := &fakeRancher{objects: map[string]map[string]any{}}
+
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	c, err := client.NewRancherClient(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f, c
+}
+
+// add stores the object as a resource, i.e. add("users", user)
+func (f *fakeRancher) add(t *testing.T, resource string, obj metav1.Object) {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.version++
+	obj.SetResourceVersion(strconv.Itoa(f.version))
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := map[string]any{}
+	if err := json.Unmarshal(b, &object); err != nil {
+		t.Fatal(err)
+	}
+
+	f.objects[objectKey(resource, obj.GetNamespace(), obj.GetName())] = object
+}
+
+// get decodes the stored object in into, returning false if not found
+func (f *fakeRancher) get(t *testing.T, resource, namespace, name string, into any) bool {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	object, found := f.objects[objectKey(resource, namespace, name)]
+	if !found {
+		return false
+	}
+
+	b, _ := json.Marshal(object)
+	if err := json.Unmarshal(b, into); err != nil {
+		t.Fatal(err)
+	}
+	return true
+}
+
+// names returns the sorted names of the stored objects of the resource in the namespace
+func (f *fakeRancher) names(resource, namespace string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var names []string
+	for key := range f.objects {
+		if prefix := objectKey(resource, namespace, ""); strings.HasPrefix(key, prefix) {
+			names = append(names, strings.TrimPrefix(key, prefix))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func objectKey(resource, namespace, name string) string {
+	return resource + "/" + namespace + "/" + name
+}
+
+func (f *fakeRancher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// /api/v1/[namespaces/<namespace>/]<resource>[/<name>] or /apis/<group>/<version>/...
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if segments[0] == "api" {
+		segments = segments[2:]
+	} else {
+		segments = segments[3:]
+	}
+
+	var namespace, name string
+	if len(segments) >= 3 && segments[0] == "namespaces" {
+		namespace, segments = segments[1], segments[2:]
+	}
+	resource := segments[0]
+	if len(segments) > 1 {
+		name = segments[1]
+	}
+
+	if f.onRequest != nil {
+		f.onRequest(r.Method, resource, name)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, fmt.Sprintf("%s %s", r.Method, strings.TrimSuffix(objectKey(resource, namespace, name), "/")))
+
+	key := objectKey(resource, namespace, name)
+	stored, found := f.objects[key]
+	groupResource := schema.GroupResource{Group: apiv3.SchemeGroupVersion.Group, Resource: resource}
+
+	if name == "" && r.Method == http.MethodGet {
+		var keys []string
+		for itemKey := range f.objects {
+			itemResource, itemNamespace, _ := strings.Cut(itemKey, "/")
+			itemNamespace, _, _ = strings.Cut(itemNamespace, "/")
+			if itemResource == resource && (namespace == "" || itemNamespace == namespace) {
+				keys = append(keys, itemKey)
+			}
+		}
+		sort.Strings(keys)
+
+		items := []map[string]any{}
+		for _, itemKey := range keys {
+			items = append(items, f.objects[itemKey])
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"metadata": map[string]any{}, "items": items})
+		return
+	}
+
+	var body map[string]any
+	if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeStatus(w, apierrors.NewBadRequest(err.Error()))
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !found {
+			writeStatus(w, apierrors.NewNotFound(groupResource, name))
+			return
+		}
+		writeJSON(w, http.StatusOK, stored)
+
+	case http.MethodPost:
+		metadata, _ := body["metadata"].(map[string]any)
+		if metadata == nil {
+			metadata = map[string]any{}
+			body["metadata"] = metadata
+		}
+		name, _ = metadata["name"].(string)
+		if name == "" {
+			generateName, _ := metadata["generateName"].(string)
+			name = fmt.Sprintf("%s%d", generateName, f.version+1)
+		}
+		key = objectKey(resource, namespace, name)
+		if _, exists := f.objects[key]; exists {
+			writeStatus(w, apierrors.NewAlreadyExists(groupResource, name))
+			return
+		}
+
+		metadata["name"], metadata["namespace"] = name, namespace
+		f.store(key, body)
+		writeJSON(w, http.StatusCreated, body)
+
+	case http.MethodPut, http.MethodPatch:
+		if !found {
+			writeStatus(w, apierrors.NewNotFound(groupResource, name))
+			return
+		}
+
+		metadata, _ := body["metadata"].(map[string]any)
+		if version, _ := metadata["resourceVersion"].(string); version != "" && version != resourceVersion(stored) {
+			writeStatus(w, apierrors.NewConflict(groupResource, name, errors.New("the object has been modified")))
+			return
+		}
+
+		if r.Method == http.MethodPatch {
+			body = mergePatch(stored, body)
+		}
+		f.store(key, body)
+		writeJSON(w, http.StatusOK, body)
+
+	case http.MethodDelete:
+		if !found {
+			writeStatus(w, apierrors.NewNotFound(groupResource, name))
+			return
+		}
+		delete(f.objects, key)
+		writeJSON(w, http.StatusOK, metav1.Status{TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}, Status: metav1.StatusSuccess})
+
+	default:
+		writeStatus(w, apierrors.NewMethodNotSupported(groupResource, r.Method))
+	}
+}
+
+// store saves the object with a new resourceVersion
+func (f *fakeRancher) store(key string, object map[string]any) {
+	f.version++
+
+	metadata, _ := object["metadata"].(map[string]any)
+	if metadata == nil {
+		metadata = map[string]any{}
+		object["metadata"] = metadata
+	}
+	metadata["resourceVersion"] = strconv.Itoa(f.version)
+
+	f.objects[key] = object
+}
+
+func resourceVersion(object map[string]any) string {
+	metadata, _ := object["metadata"].(map[string]any)
+	version, _ := metadata["resourceVersion"].(string)
+	return version
+}
+
+// mergePatch applies a JSON merge patch (RFC 7386) to the object
+func mergePatch(object, patch map[string]any) map[string]any {
+	merged := map[string]any{}
+	for k, v := range object {
+		merged[k] = v
+	}
+
+	for k, v := range patch {
+		switch v := v.(type) {
+		case nil:
+			delete(merged, k)
+		case map[string]any:
+			current, _ := merged[k].(map[string]any)
+			merged[k] = mergePatch(current, v)
+		default:
+			merged[k] = v
+		}
+	}
+
+	return merged
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(obj)
+}
+
+func writeStatus(w http.ResponseWriter, err *apierrors.StatusError) {
+	status := err.ErrStatus
+	status.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+	writeJSON(w, int(status.Code), status)
+}
+
+// newTestUser adds a User with the principal and a PRTB of the principal, and returns its MigratableResource
+func newTestUser(t *testing.T, f *fakeRancher, name, dn, uuid string) *MigratableResource {
+	t.Helper()
+
+	principalID := "activedirectory_user://" + dn
+	objectGUID, err := guid.Parse(uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.add(t, "users", &apiv3.User{
+		ObjectMeta:   metav1.ObjectMeta{Name: name},
+		PrincipalIDs: []string{"local://" + name, principalID},
+	})
+	f.add(t, "projectroletemplatebindings", &apiv3.ProjectRoleTemplateBinding{
+		ObjectMeta:        metav1.ObjectMeta{Name: "prtb-" + name, Namespace: "p-1"},
+		ProjectName:       "c-1:p-1",
+		RoleTemplateName:  "project-member",
+		UserPrincipalName: principalID,
+	})
+
+	user := &apiv3.User{}
+	f.get(t, "users", "", name, user)
+	prtb := &apiv3.ProjectRoleTemplateBinding{}
+	f.get(t, "projectroletemplatebindings", "p-1", "prtb-"+name, prtb)
+
+	return &MigratableResource{
+		User:        user,
+		PrincipalID: principalID,
+		DN:          dn,
+		GUID:        objectGUID,
+		Bindings:    []PrincipalIDResource{&PRTBResource{PRTB: prtb}},
+	}
+}
+
+func TestUpdateResourcesInterrupted(t *testing.T) {
+	f, c := newFakeRancher(t)
+
+	john := newTestUser(t, f, "u-john", "CN=john,DC=example,DC=com", "00112233-4455-6677-8899-aabbccddeeff")
+	jane := newTestUser(t, f, "u-jane", "CN=jane,DC=example,DC=com", "ffeeddcc-bbaa-9988-7766-554433221100")
+
+	// the interrupt arrives while the first principal is updated
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.onRequest = func(method, resource, name string) {
+		if method == http.MethodPut && resource == "users" {
+			cancel()
+		}
+	}
+
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	opts := migrations.ApplyOptions{RunID: "run-1", StateFile: stateFile}
+
+	err := updateResources(ctx, c, migrations.OperationMigrate, []*MigratableResource{john, jane}, opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("updateResources() error = %v, want %v", err, context.Canceled)
+	}
+
+	// the interrupted principal is completed
+	user := &apiv3.User{}
+	f.get(t, "users", "", "u-john", user)
+	if !slices.Contains(user.PrincipalIDs, GetUpdatedPrincipalID(john)) || user.Annotations[RunIDAnnotation] != "run-1" {
+		t.Errorf("user u-john = %v %v, want migrated by run-1", user.PrincipalIDs, user.Annotations)
+	}
+	if prtbs := f.names("projectroletemplatebindings", "p-1"); len(prtbs) != 2 || slices.Contains(prtbs, "prtb-u-john") {
+		t.Errorf("PRTBs = %v, want prtb-u-john replaced", prtbs)
+	}
+
+	// the next one is not started
+	f.get(t, "users", "", "u-jane", user)
+	if !slices.Contains(user.PrincipalIDs, jane.PrincipalID) {
+		t.Errorf("user u-jane = %v, want not updated", user.PrincipalIDs)
+	}
+
+	state, err := migrations.LoadState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(state.Completed, []string{john.PrincipalID}) || !slices.Equal(state.Pending, []string{jane.PrincipalID}) {
+		t.Errorf("state = completed %v, pending %v, want completed %s, pending %s", state.Completed, state.Pending, john.PrincipalID, jane.PrincipalID)
+	}
+}
@@ -0,0 +1,190 @@
+package version_1_10_0
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// MaintenanceAnnotation is the access of the activedirectory authconfig saved before the maintenance,
+	// removed when it is restored
+	MaintenanceAnnotation = "rancher-migrate/maintenance"
+
+	// maintenanceAccessMode allows the logins only of the allowed principals: unlike "restricted",
+	// it does not admit the members of the clusters and projects
+	maintenanceAccessMode = "required"
+)
+
+// Maintenance is the access of the activedirectory authconfig before the maintenance
+type Maintenance struct {
+	AccessMode          string    `json:"accessMode"`
+	AllowedPrincipalIDs []string  `json:"allowedPrincipalIds"`
+	RunID               string    `json:"runId"`
+	Since               time.Time `json:"since"`
+}
+
+// getMaintenance returns the activedirectory authconfig and its saved Maintenance, nil if it is not in maintenance
+func getMaintenance(ctx context.Context, c *client.RancherClient) (*apiv3.ActiveDirectoryConfig, *Maintenance, error) {
+	adConfig := &apiv3.ActiveDirectoryConfig{}
+	err := c.Rancher.Get().Resource("authconfigs").Name(ad.Name).Do(ctx).Into(adConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting activedirectory authconfig: %w", err)
+	}
+
+	value, found := adConfig.Annotations[MaintenanceAnnotation]
+	if !found {
+		return adConfig, nil, nil
+	}
+
+	maintenance := &Maintenance{}
+	err = json.Unmarshal([]byte(value), maintenance)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s annotation of the activedirectory authconfig: %w", MaintenanceAnnotation, err)
+	}
+
+	return adConfig, maintenance, nil
+}
+
+// startMaintenance restricts the logins of the activedirectory authconfig to the allowed principals,
+// saving its previous access in the MaintenanceAnnotation. It fails if a previous maintenance was not restored.
+func startMaintenance(ctx context.Context, c *client.RancherClient, allowedPrincipalIDs []string, runID string) error {
+	adConfig, previous, err := getMaintenance(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	if previous != nil {
+		return fmt.Errorf(
+			"the activedirectory authconfig is still in maintenance since %s (run %s): "+
+				"restore its access with the restore-maintenance command",
+			previous.Since.Format(time.RFC3339), previous.RunID,
+		)
+	}
+
+	maintenance, err := json.Marshal(&Maintenance{
+		AccessMode:          adConfig.AccessMode,
+		AllowedPrincipalIDs: adConfig.AllowedPrincipalIDs,
+		RunID:               runID,
+		Since:               time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if allowedPrincipalIDs == nil {
+		allowedPrincipalIDs = []string{}
+	}
+
+	// the resourceVersion fails the patch if the authconfig was updated meanwhile
+	err = patchAuthConfig(ctx, c, map[string]any{
+		"metadata": map[string]any{
+			"resourceVersion": adConfig.ResourceVersion,
+			"annotations":     map[string]any{MaintenanceAnnotation: string(maintenance)},
+		},
+		"accessMode":          maintenanceAccessMode,
+		"allowedPrincipalIds": allowedPrincipalIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot start maintenance: %w", err)
+	}
+
+	slog.Warn("maintenance started", "runID", runID, "previousAccessMode", adConfig.AccessMode, "allowedPrincipals", allowedPrincipalIDs)
+	logging.Printf(
+		"%s: Active Directory logins restricted to %d principals until the end of the run\n",
+		yellow("maintenance"), len(allowedPrincipalIDs),
+	)
+
+	return nil
+}
+
+// restoreMaintenance restores the access of the activedirectory authconfig saved in the MaintenanceAnnotation
+func restoreMaintenance(ctx context.Context, c *client.RancherClient) error {
+	_, maintenance, err := getMaintenance(ctx, c)
+	if err != nil {
+		return err
+	}
+	if maintenance == nil {
+		return nil
+	}
+
+	allowedPrincipalIDs := maintenance.AllowedPrincipalIDs
+	if allowedPrincipalIDs == nil {
+		allowedPrincipalIDs = []string{}
+	}
+
+	err = patchAuthConfig(ctx, c, map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]any{MaintenanceAnnotation: nil},
+		},
+		"accessMode":          maintenance.AccessMode,
+		"allowedPrincipalIds": allowedPrincipalIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot restore maintenance: %w", err)
+	}
+
+	slog.Info("maintenance restored", "runID", maintenance.RunID, "accessMode", maintenance.AccessMode)
+	logging.Printf("%s: Active Directory access restored (%s)\n", green("maintenance"), maintenance.AccessMode)
+
+	return nil
+}
+
+// StartMaintenance restricts the Active Directory logins to the allowed principals, if the maintenance is enabled.
+// The returned restore restores the access also if the context was canceled. The maintenance cannot be used
+// with the Watch, as it would block the logins creating the principals to watch.
+func (m *Migration) StartMaintenance(ctx context.Context, opts migrations.ApplyOptions) (func() error, error) {
+	if !m.opts.Maintenance {
+		return func() error { return nil }, nil
+	}
+
+	if opts.Watch > 0 {
+		return nil, fmt.Errorf("cannot use --maintenance with --watch: the maintenance blocks the logins of the new principals")
+	}
+
+	err := startMaintenance(ctx, m.client, m.opts.MaintenanceAllowedPrincipals, opts.RunID)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		err := restoreMaintenance(context.WithoutCancel(ctx), m.client)
+		if err != nil {
+			return fmt.Errorf("%w: restore it with the restore-maintenance command", err)
+		}
+		return nil
+	}, nil
+}
+
+// RestoreMaintenance restores the access of the activedirectory authconfig saved by a maintenance that was not restored
+func (m *Migration) RestoreMaintenance(ctx context.Context, c *client.RancherClient) error {
+	_, maintenance, err := getMaintenance(ctx, c)
+	if err != nil {
+		return err
+	}
+	if maintenance == nil {
+		logging.Println("The activedirectory authconfig is not in maintenance")
+		return nil
+	}
+
+	return restoreMaintenance(ctx, c)
+}
+
+// patchAuthConfig applies a JSON merge patch to the activedirectory authconfig, keeping the fields unknown to the client
+func patchAuthConfig(ctx context.Context, c *client.RancherClient, patch map[string]any) error {
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	return c.Rancher.Patch(types.MergePatchType).Resource("authconfigs").Name(ad.Name).Body(b).Do(ctx).Error()
+}
@@ -0,0 +1,115 @@
+package version_1_10_0
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestMaintenance(t *testing.T) {
+	f, c := newFakeRancher(t)
+
+	adConfig := newADConfig(true)
+	adConfig.AccessMode = "restricted"
+	adConfig.AllowedPrincipalIDs = []string{"local://u-admin"}
+	f.add(t, "authconfigs", adConfig)
+
+	m := &Migration{client: c}
+	m.opts.Maintenance = true
+	m.opts.MaintenanceAllowedPrincipals = []string{"activedirectory_user://CN=admin,DC=example,DC=com"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	restore, err := m.StartMaintenance(ctx, migrations.ApplyOptions{RunID: "run-1"})
+	if err != nil {
+		t.Fatalf("StartMaintenance() error = %v", err)
+	}
+
+	got := &apiv3.ActiveDirectoryConfig{}
+	f.get(t, "authconfigs", "", ad.Name, got)
+	if got.AccessMode != maintenanceAccessMode || !slices.Equal(got.AllowedPrincipalIDs, m.opts.MaintenanceAllowedPrincipals) {
+		t.Errorf("authconfig access = %s %v, want restricted to the allowed principals", got.AccessMode, got.AllowedPrincipalIDs)
+	}
+	if !got.Enabled || got.Annotations[MaintenanceAnnotation] == "" {
+		t.Errorf("authconfig = enabled %t, annotations %v, want enabled with the saved access", got.Enabled, got.Annotations)
+	}
+
+	// a second run cannot start while in maintenance
+	_, err = m.StartMaintenance(context.Background(), migrations.ApplyOptions{RunID: "run-2"})
+	if err == nil || !strings.Contains(err.Error(), "still in maintenance") || !strings.Contains(err.Error(), "run-1") {
+		t.Errorf("StartMaintenance() error = %v, want still in maintenance by run-1", err)
+	}
+
+	// the access is restored also if the run was interrupted
+	cancel()
+	err = restore()
+	if err != nil {
+		t.Fatalf("restore() error = %v", err)
+	}
+
+	got = &apiv3.ActiveDirectoryConfig{}
+	f.get(t, "authconfigs", "", ad.Name, got)
+	if got.AccessMode != "restricted" || !slices.Equal(got.AllowedPrincipalIDs, []string{"local://u-admin"}) {
+		t.Errorf("authconfig access = %s %v, want the previous access restored", got.AccessMode, got.AllowedPrincipalIDs)
+	}
+	if _, found := got.Annotations[MaintenanceAnnotation]; found {
+		t.Errorf("authconfig annotations = %v, want the maintenance annotation removed", got.Annotations)
+	}
+
+	// nothing is patched when not in maintenance
+	f.requests = nil
+	err = m.RestoreMaintenance(context.Background(), c)
+	if err != nil || slices.ContainsFunc(f.requests, func(request string) bool { return strings.HasPrefix(request, http.MethodPatch) }) {
+		t.Errorf("RestoreMaintenance() = %v, requests %v, want no patch", err, f.requests)
+	}
+}
+
+func TestStartMaintenanceConflict(t *testing.T) {
+	f, c := newFakeRancher(t)
+	f.add(t, "authconfigs", newADConfig(true))
+
+	// the authconfig is updated between the get and the patch
+	f.onRequest = func(method, resource, name string) {
+		if method == http.MethodPatch {
+			updated := newADConfig(true)
+			updated.AccessMode = "restricted"
+			f.add(t, "authconfigs", updated)
+		}
+	}
+
+	err := startMaintenance(context.Background(), c, nil, "run-1")
+	if !apierrors.IsConflict(err) {
+		t.Fatalf("startMaintenance() error = %v, want a conflict", err)
+	}
+
+	got := &apiv3.ActiveDirectoryConfig{}
+	f.get(t, "authconfigs", "", ad.Name, got)
+	if got.AccessMode != "restricted" || got.Annotations[MaintenanceAnnotation] != "" {
+		t.Errorf("authconfig = %s %v, want the concurrent update kept", got.AccessMode, got.Annotations)
+	}
+}
+
+func TestStartMaintenanceDisabled(t *testing.T) {
+	f, c := newFakeRancher(t)
+	f.add(t, "authconfigs", newADConfig(true))
+
+	m := &Migration{client: c}
+
+	restore, err := m.StartMaintenance(context.Background(), migrations.ApplyOptions{RunID: "run-1"})
+	if err != nil || restore() != nil || len(f.requests) != 0 {
+		t.Errorf("StartMaintenance() error = %v, requests %v, want nothing done without --maintenance", err, f.requests)
+	}
+
+	m.opts.Maintenance = true
+	_, err = m.StartMaintenance(context.Background(), migrations.ApplyOptions{RunID: "run-1", Watch: time.Minute})
+	if err == nil || !strings.Contains(err.Error(), "--watch") || len(f.requests) != 0 {
+		t.Errorf("StartMaintenance() error = %v, requests %v, want --watch rejected", err, f.requests)
+	}
+}
@@ -0,0 +1,235 @@
+package version_1_10_0
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+)
+
+// the columns of a mapping file: only the DN and the objectGUID are required,
+// the others are written by ExportMapping for the review of the mapping
+const (
+	mappingPrincipalColumn   = "principalID"
+	mappingDNColumn          = "dn"
+	mappingGUIDColumn        = "objectGUID"
+	mappingAccountNameColumn = "sAMAccountName"
+	mappingCurrentDNColumn   = "currentDN"
+	mappingSourceColumn      = "source"
+	mappingMatchedByColumn   = "matchedBy"
+)
+
+// ReadMapping returns the entries of a CSV mapping file with the DN and the objectGUID of the users, i.e.:
+//
+//	dn,objectGUID
+//	"CN=John Doe,OU=Users,DC=example,DC=com",efbeadde-2301-6745-89ab-cdef01234567
+//
+// The header is optional, without it the first two columns are the DN and the objectGUID, in any of
+// the encodings of ParseGUID. A file written by ExportMapping can be read as well.
+// The mapping must be one-to-one: a DN or an objectGUID cannot be mapped twice.
+func ReadMapping(path string) ([]DirectoryEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open mapping file: %w", err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var (
+		entries        []DirectoryEntry
+		dnCol, guidCol = 0, 1
+		accountNameCol = -1
+		errs           []error
+	)
+
+	byDN := map[string]string{}
+	byGUID := map[string]string{}
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid mapping file '%s': %w", path, err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first && isMappingHeader(record) {
+			dnCol, guidCol = -1, -1
+			for i, column := range record {
+				switch {
+				case strings.EqualFold(column, mappingDNColumn):
+					dnCol = i
+				case strings.EqualFold(column, mappingGUIDColumn):
+					guidCol = i
+				case strings.EqualFold(column, mappingAccountNameColumn):
+					accountNameCol = i
+				}
+			}
+			if dnCol < 0 || guidCol < 0 {
+				return nil, fmt.Errorf("invalid mapping file '%s': missing %s or %s column", path, mappingDNColumn, mappingGUIDColumn)
+			}
+			continue
+		}
+
+		if len(record) <= dnCol || len(record) <= guidCol {
+			return nil, fmt.Errorf("invalid mapping file '%s': line %d: missing DN or objectGUID", path, line)
+		}
+
+		dn := strings.TrimPrefix(strings.TrimSpace(record[dnCol]), ad.UserScope+"://")
+		objectGUID, _, err := ParseGUID(strings.TrimSpace(record[guidCol]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid mapping file '%s': line %d: %w", path, line, err)
+		}
+
+		dnKey, guidKey := strings.ToLower(dn), objectGUID.UUID()
+
+		mappedGUID, dnFound := byDN[dnKey]
+		mappedDN, guidFound := byGUID[guidKey]
+
+		switch {
+		case dnFound && mappedGUID == guidKey:
+			// duplicated line
+			continue
+		case dnFound:
+			errs = append(errs, fmt.Errorf("line %d: DN '%s' is mapped to both %s and %s", line, dn, mappedGUID, guidKey))
+			continue
+		case guidFound:
+			errs = append(errs, fmt.Errorf("line %d: objectGUID %s is mapped to both '%s' and '%s'", line, guidKey, mappedDN, dn))
+			continue
+		}
+
+		entry := DirectoryEntry{DN: dn, GUID: objectGUID}
+		if accountNameCol >= 0 && accountNameCol < len(record) {
+			entry.SAMAccountName = strings.TrimSpace(record[accountNameCol])
+		}
+
+		byDN[dnKey], byGUID[guidKey] = guidKey, dn
+		entries = append(entries, entry)
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("mapping file '%s' is not one-to-one:\n%w", path, errors.Join(errs...))
+	}
+
+	slog.Debug("mapping file read", "path", path, "entries", len(entries))
+
+	return entries, nil
+}
+
+// isMappingHeader returns true if the record has a dn column, that cannot be a DN
+func isMappingHeader(record []string) bool {
+	for _, column := range record {
+		if strings.EqualFold(strings.TrimSpace(column), mappingDNColumn) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMappingCoverage returns an error listing all the principals that cannot be resolved with the mapping
+func checkMappingCoverage(path string, resolver Resolver, migratable MigratableResources) error {
+	objectGUIDPrincipalPrefix := fmt.Sprintf("%s://%s=", ad.UserScope, ad.ObjectGUIDAttribute)
+
+	var missing []string
+
+	for _, principalID := range migratable.PrincipalIDs() {
+		var err error
+
+		if value, found := strings.CutPrefix(principalID, objectGUIDPrincipalPrefix); found {
+			var objectGUID guid.GUID
+			objectGUID, err = guid.Parse(value)
+			if err == nil {
+				_, err = resolver.GetDN(objectGUID)
+			}
+		} else {
+			_, _, err = resolver.GetGUID(strings.TrimPrefix(principalID, ad.UserScope+"://"))
+		}
+
+		if err != nil {
+			missing = append(missing, principalID)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("mapping file '%s' does not cover %d principals:\n\t%s", path, len(missing), strings.Join(missing, "\n\t"))
+	}
+	return nil
+}
+
+// ExportMapping writes the resolved DN and objectGUID of every discovered principal in a CSV file,
+// with its sAMAccountName and where it was found. The file can be used with the --mapping flag.
+func (m *Migration) ExportMapping(ctx context.Context, discovery migrations.Discovery, path string) error {
+	migratable, ok := discovery.(MigratableResources)
+	if !ok {
+		return fmt.Errorf("invalid discovery type %T", discovery)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot create mapping file: %w", err)
+	}
+	defer f.Close()
+
+	writer := csv.NewWriter(f)
+	err = writer.Write([]string{
+		mappingPrincipalColumn, mappingDNColumn, mappingGUIDColumn, mappingAccountNameColumn,
+		mappingCurrentDNColumn, mappingSourceColumn, mappingMatchedByColumn,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot write mapping file: %w", err)
+	}
+
+	for _, principalID := range migratable.PrincipalIDs() {
+		res := migratable[principalID]
+
+		err = writer.Write([]string{
+			res.PrincipalID,
+			res.DN,
+			res.GUID.UUID(),
+			res.Match.AccountName,
+			res.Match.DN,
+			res.Match.Source,
+			matchedBy(res),
+		})
+		if err != nil {
+			return fmt.Errorf("cannot write mapping file: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("cannot write mapping file: %w", err)
+	}
+
+	slog.Info("mapping exported", "path", path, "principals", len(migratable))
+	logging.Printf("\nMapping of %d principals exported in '%s'\n", len(migratable), path)
+
+	return f.Close()
+}
+
+// matchedBy returns how the principal was resolved: by its objectGUID, its exact DN,
+// or by the account name attribute matching the RDN of its DN
+func matchedBy(res *MigratableResource) string {
+	switch {
+	case strings.Contains(res.PrincipalID, ad.ObjectGUIDAttribute):
+		return ad.ObjectGUIDAttribute
+	case res.Match.Exact():
+		return mappingDNColumn
+	}
+	return res.Match.Attribute
+}
@@ -0,0 +1,179 @@
+package version_1_10_0
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+)
+
+func TestReadMapping(t *testing.T) {
+	const (
+		guid1 = "00112233-4455-6677-8899-aabbccddeeff"
+		guid2 = "ffeeddcc-bbaa-9988-7766-554433221100"
+	)
+
+	tests := []struct {
+		name string
+		csv  string
+		// want are the DN and the objectGUID of the entries
+		want    [][2]string
+		wantErr string
+	}{
+		{
+			name: "without header",
+			csv:  "\"CN=John Doe,DC=example,DC=com\"," + guid1 + "\n",
+			want: [][2]string{{"CN=John Doe,DC=example,DC=com", guid1}},
+		},
+		{
+			name: "with header, principal prefix and comments",
+			csv: "objectGUID,dn,sAMAccountName\n" +
+				"# reviewed\n" +
+				guid1 + ",\"activedirectory_user://CN=John Doe,DC=example,DC=com\",jdoe\n",
+			want: [][2]string{{"CN=John Doe,DC=example,DC=com", guid1}},
+		},
+		{
+			name: "other encodings",
+			csv: "dn,objectGUID\n" +
+				"\"CN=John Doe,DC=example,DC=com\",MyIRAFVEd2aImaq7zN3u/w==\n" +
+				"\"CN=Jane Doe,DC=example,DC=com\",ccddeeff aabb 8899 7766554433221100\n",
+			want: [][2]string{{"CN=John Doe,DC=example,DC=com", guid1}, {"CN=Jane Doe,DC=example,DC=com", guid2}},
+		},
+		{
+			name: "duplicated line",
+			csv: "\"CN=John Doe,DC=example,DC=com\"," + guid1 + "\n" +
+				"\"cn=john doe,dc=example,dc=com\"," + guid1 + "\n",
+			want: [][2]string{{"CN=John Doe,DC=example,DC=com", guid1}},
+		},
+		{
+			name: "duplicate DN",
+			csv: "\"CN=John Doe,DC=example,DC=com\"," + guid1 + "\n" +
+				"\"cn=john doe,dc=example,dc=com\"," + guid2 + "\n",
+			wantErr: "DN 'cn=john doe,dc=example,dc=com' is mapped to both",
+		},
+		{
+			name: "duplicate objectGUID",
+			csv: "\"CN=John Doe,DC=example,DC=com\"," + guid1 + "\n" +
+				"\"CN=Jane Doe,DC=example,DC=com\"," + guid1 + "\n",
+			wantErr: "objectGUID " + guid1 + " is mapped to both",
+		},
+		{
+			name:    "missing column in header",
+			csv:     "dn,sAMAccountName\n\"CN=John Doe,DC=example,DC=com\",jdoe\n",
+			wantErr: "missing dn or objectGUID column",
+		},
+		{
+			name:    "missing objectGUID",
+			csv:     "\"CN=John Doe,DC=example,DC=com\"\n",
+			wantErr: "line 1: missing DN or objectGUID",
+		},
+		{
+			name:    "invalid objectGUID",
+			csv:     "\"CN=John Doe,DC=example,DC=com\",not-a-guid\n",
+			wantErr: "line 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "mapping.csv")
+			err := os.WriteFile(path, []byte(tt.csv), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := ReadMapping(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadMapping() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadMapping() unexpected error: %v", err)
+			}
+
+			if len(entries) != len(tt.want) {
+				t.Fatalf("ReadMapping() returned %d entries, want %d", len(entries), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if entries[i].DN != want[0] {
+					t.Errorf("entry %d: DN = %q, want %q", i, entries[i].DN, want[0])
+				}
+				if got := entries[i].GUID.UUID(); got != want[1] {
+					t.Errorf("entry %d: objectGUID = %s, want %s", i, got, want[1])
+				}
+			}
+		})
+	}
+}
+
+func TestExportMapping(t *testing.T) {
+	output := logging.Output
+	logging.Output = io.Discard
+	t.Cleanup(func() { logging.Output = output })
+
+	johnGUID, _ := guid.Parse("00112233-4455-6677-8899-aabbccddeeff")
+	janeGUID, _ := guid.Parse("ffeeddcc-bbaa-9988-7766-554433221100")
+
+	moved := newMatch("CN=John Doe,OU=Moved,DC=example,DC=com", "sAMAccountName", SearchTarget{Name: "ldap.example.com"})
+	moved.AccountName = "john"
+
+	migratable := MigratableResources{
+		"activedirectory_user://CN=john,OU=Users,DC=example,DC=com": {
+			PrincipalID: "activedirectory_user://CN=john,OU=Users,DC=example,DC=com",
+			DN:          "CN=john,OU=Users,DC=example,DC=com",
+			GUID:        johnGUID,
+			Match:       moved,
+		},
+		"activedirectory_user://objectGUID=ffeeddcc-bbaa-9988-7766-554433221100": {
+			PrincipalID: "activedirectory_user://objectGUID=ffeeddcc-bbaa-9988-7766-554433221100",
+			DN:          "CN=jane,OU=Users,DC=example,DC=com",
+			GUID:        janeGUID,
+			Match:       newMatch("CN=jane,OU=Users,DC=example,DC=com", "", SearchTarget{Name: "ldap.example.com"}),
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "mapping.csv")
+	err := (&Migration{}).ExportMapping(context.Background(), migratable, path)
+	if err != nil {
+		t.Fatalf("ExportMapping() error = %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "principalID,dn,objectGUID,sAMAccountName,currentDN,source,matchedBy\n" +
+		"\"activedirectory_user://CN=john,OU=Users,DC=example,DC=com\",\"CN=john,OU=Users,DC=example,DC=com\",00112233-4455-6677-8899-aabbccddeeff,john,\"CN=John Doe,OU=Moved,DC=example,DC=com\",ldap.example.com,sAMAccountName\n" +
+		"activedirectory_user://objectGUID=ffeeddcc-bbaa-9988-7766-554433221100,\"CN=jane,OU=Users,DC=example,DC=com\",ffeeddcc-bbaa-9988-7766-554433221100,,\"CN=jane,OU=Users,DC=example,DC=com\",ldap.example.com,objectGUID\n"
+	if string(b) != want {
+		t.Errorf("ExportMapping() wrote\n%s\nwant\n%s", b, want)
+	}
+
+	// the exported mapping can be read back, and covers all the principals
+	entries, err := ReadMapping(path)
+	if err != nil {
+		t.Fatalf("ReadMapping() error = %v", err)
+	}
+	resolver := NewDirectoryResolver(path, entries)
+	resolver.RequireExactDN = true
+
+	err = checkMappingCoverage(path, resolver, migratable)
+	if err != nil {
+		t.Errorf("checkMappingCoverage() error = %v", err)
+	}
+
+	migratable["activedirectory_user://CN=joe,OU=Users,DC=example,DC=com"] = &MigratableResource{
+		PrincipalID: "activedirectory_user://CN=joe,OU=Users,DC=example,DC=com",
+	}
+	err = checkMappingCoverage(path, resolver, migratable)
+	if err == nil || !strings.Contains(err.Error(), "does not cover 1 principals") || !strings.Contains(err.Error(), "CN=joe") {
+		t.Errorf("checkMappingCoverage() error = %v, want CN=joe not covered", err)
+	}
+}
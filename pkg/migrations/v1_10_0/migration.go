@@ -0,0 +1,460 @@
+package version_1_10_0
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	"github.com/spf13/pflag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+func init() {
+	migrations.Register(&Migration{})
+}
+
+// Migration migrates the Active Directory principals from the DN to the objectGUID format
+type Migration struct {
+	opts     Options
+	client   *client.RancherClient
+	resolver Resolver
+	policy   *Policy
+}
+
+// Options are the flags of the v1.10.0 migration
+type Options struct {
+	RequireExactDN       bool
+	GlobalCatalog        bool
+	GlobalCatalogServers []string
+	SearchBases          []string
+	FollowReferrals      bool
+	MaxReferralHops      int
+
+	// SnapshotFile is where the resources of the principals are counted before their migration
+	SnapshotFile string
+	// VerifyGroups verifies also the group principals of the bindings
+	VerifyGroups bool
+	// LDIF is an export of Active Directory used instead of connecting to it
+	LDIF string
+	// Policy is a YAML file with the principals excluded or requiring a confirmation
+	Policy string
+	// Mapping is a CSV file mapping the DNs to the objectGUIDs, used instead of connecting to Active Directory
+	Mapping string
+	// RancherCompat writes the status and the annotations of Rancher's ad-guid-migration
+	RancherCompat bool
+	// IncludeRancherMigrated migrates also the principals marked as migrated by Rancher's ad-guid-migration
+	IncludeRancherMigrated bool
+	// Maintenance restricts the Active Directory logins to the MaintenanceAllowedPrincipals from the confirmation
+	// to the end of the update
+	Maintenance                  bool
+	MaintenanceAllowedPrincipals []string
+
+	LDAP           client.LDAPOverrides
+	LDAPConfigFile string
+}
+
+func (m *Migration) ID() string {
+	return "v1.10.0"
+}
+
+func (m *Migration) Description() string {
+	return "Migrate the Active Directory principals from DN to objectGUID"
+}
+
+func (m *Migration) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(
+		&m.opts.RequireExactDN, "require-exact-dn", false,
+		"disable the lookup by sAMAccountName/userPrincipalName of the users whose DN was not found",
+	)
+	fs.BoolVar(
+		&m.opts.GlobalCatalog, "global-catalog", false,
+		"search the users of the whole forest in the Global Catalog (port 3268, or 3269 with TLS)",
+	)
+	fs.StringSliceVar(
+		&m.opts.GlobalCatalogServers, "global-catalog-server", nil,
+		"Global Catalog servers (defaults to the activedirectory servers, implies --global-catalog)",
+	)
+	fs.StringSliceVar(
+		&m.opts.SearchBases, "search-base", nil,
+		"additional user search base, as a DN or as an LDAP URL of another domain controller "+
+			"(i.e. ldaps://dc1.child.example.com/DC=child,DC=example,DC=com)",
+	)
+	fs.BoolVar(
+		&m.opts.FollowReferrals, "follow-referrals", false,
+		"follow the LDAP referrals to other naming contexts, binding with the same service account",
+	)
+	fs.IntVar(
+		&m.opts.MaxReferralHops, "max-referral-hops", 3,
+		"maximum number of consecutive referrals followed by a search",
+	)
+	fs.StringVar(
+		&m.opts.SnapshotFile, "snapshot-file", "rancher-migrate-snapshot.json",
+		"file where the resources of the principals are counted before the migration, checked by verify",
+	)
+	fs.BoolVar(
+		&m.opts.VerifyGroups, "verify-groups", false,
+		"verify also that no group principals in the DN format are left in the bindings",
+	)
+	fs.StringVar(
+		&m.opts.LDIF, "ldif", "",
+		"LDIF export of the Active Directory users (with DN, objectGUID and optionally sAMAccountName) "+
+			"used instead of connecting to Active Directory",
+	)
+	fs.StringVar(
+		&m.opts.Mapping, "mapping", "",
+		"CSV file mapping the DN to the objectGUID of every principal (dn,objectGUID) "+
+			"used instead of connecting to Active Directory",
+	)
+	fs.StringVar(
+		&m.opts.Policy, "policy", "",
+		"YAML policy file with the principals, usernames, DN patterns and clusters excluded from the updates "+
+			"or requiring a confirmation (updated only when selected by principal ID)",
+	)
+	fs.BoolVar(
+		&m.opts.RancherCompat, "rancher-compat", false,
+		"write the finished status of Rancher's ad-guid-migration ConfigMap after the update, and its annotations "+
+			"on the updated objects, "+
+			"so that Rancher does not run its migration again and revert the updated principals",
+	)
+	fs.BoolVar(
+		&m.opts.IncludeRancherMigrated, "include-rancher-migrated", false,
+		"migrate also the principals whose objects were marked as migrated by Rancher's ad-guid-migration",
+	)
+	fs.BoolVar(
+		&m.opts.Maintenance, "maintenance", false,
+		"allow the Active Directory logins only to the --maintenance-allowed-principal from the confirmation "+
+			"to the end of the update, so that no new principals are created, "+
+			"restoring the access of the activedirectory authconfig at the end (cannot be used with --watch)",
+	)
+	fs.StringArrayVar(
+		&m.opts.MaintenanceAllowedPrincipals, "maintenance-allowed-principal", nil,
+		"principal ID allowed to log in during the maintenance (i.e. a break-glass local://u-abc123)",
+	)
+	client.AddLDAPFlags(fs, &m.opts.LDAP, &m.opts.LDAPConfigFile)
+}
+
+// Init loads the LDAP flags and the policy. The connection to Active Directory is opened only when a principal is resolved.
+func (m *Migration) Init(ctx context.Context, c *client.RancherClient, fs *pflag.FlagSet) error {
+	err := client.LoadLDAPFlags(fs)
+	if err != nil {
+		return err
+	}
+
+	m.client = c
+
+	if m.opts.Policy != "" {
+		m.policy, err = LoadPolicy(m.opts.Policy)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getResolver returns the Resolver of the principals, reading the mapping file or the LDIF export,
+// or connecting to Active Directory on the first call
+func (m *Migration) getResolver(ctx context.Context) (Resolver, error) {
+	if m.resolver != nil {
+		return m.resolver, nil
+	}
+
+	if m.opts.Mapping != "" && m.opts.LDIF != "" {
+		return nil, fmt.Errorf("cannot use both --mapping and --ldif")
+	}
+
+	if m.opts.Mapping != "" {
+		entries, err := ReadMapping(m.opts.Mapping)
+		if err != nil {
+			return nil, err
+		}
+
+		// the mapping is explicit, the users are never looked up by account name
+		resolver := NewDirectoryResolver(m.opts.Mapping, entries)
+		resolver.RequireExactDN = true
+		m.resolver = resolver
+
+		return m.resolver, nil
+	}
+
+	if m.opts.LDIF != "" {
+		entries, err := ReadLDIF(m.opts.LDIF)
+		if err != nil {
+			return nil, err
+		}
+
+		resolver := NewDirectoryResolver(m.opts.LDIF, entries)
+		resolver.RequireExactDN = m.opts.RequireExactDN
+		m.resolver = resolver
+
+		return m.resolver, nil
+	}
+
+	resolver, err := NewResolver(ctx, m.client, &m.opts)
+	if err != nil {
+		return nil, err
+	}
+	m.resolver = resolver
+
+	return m.resolver, nil
+}
+
+// minRancherVersion is the first Rancher version the migration applies to
+const minRancherVersion = "v2.10.0"
+
+// Applicable returns true if the Rancher version is at least minRancherVersion and the activedirectory
+// auth provider is enabled. A version that is not a release (i.e. a development build) is considered applicable.
+func (m *Migration) Applicable(ctx context.Context, c *client.RancherClient, serverVersion string) (bool, error) {
+	v, err := version.ParseGeneric(serverVersion)
+	if err != nil {
+		slog.Warn("cannot parse rancher version", "serverVersion", serverVersion, "error", err)
+	} else if !v.AtLeast(version.MustParseGeneric(minRancherVersion)) {
+		slog.Info("rancher version not applicable", "serverVersion", serverVersion, "minVersion", minRancherVersion)
+		return false, nil
+	}
+
+	adConfig := &apiv3.ActiveDirectoryConfig{}
+	err = c.Rancher.Get().Resource("authconfigs").Name(ad.Name).Do(ctx).Into(adConfig)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting activedirectory authconfig: %w", err)
+	}
+
+	return adConfig.Enabled, nil
+}
+
+// Applied returns true if no users or bindings reference a principal in the DN format.
+// The principals are not resolved, so the LDAP connection is not needed.
+func (m *Migration) Applied(ctx context.Context, c *client.RancherClient) (bool, error) {
+	users, err := GetUsersToMigrate(ctx, c)
+	if err != nil {
+		return false, err
+	}
+
+	bindings, err := GetUserBindings(ctx, c)
+	if err != nil {
+		return false, err
+	}
+
+	for principalID := range users {
+		if !strings.Contains(principalID, ad.ObjectGUIDAttribute) {
+			return false, nil
+		}
+	}
+	for principalID := range bindings {
+		if !strings.Contains(principalID, ad.ObjectGUIDAttribute) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (m *Migration) Discover(ctx context.Context) (migrations.Discovery, error) {
+	logging.Println("Checking resources")
+
+	resolver, err := m.getResolver(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	migratable, err := ListMigratableResources(ctx, m.client)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.opts.Mapping != "" {
+		err = checkMappingCoverage(m.opts.Mapping, resolver, migratable)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = migratable.Resolve(resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	migratable.applyPolicy(m.policy)
+
+	return migratable, nil
+}
+
+func (m *Migration) Plan(ctx context.Context, discovery migrations.Discovery, selection migrations.Selection) (migrations.Plan, error) {
+	err := checkSelectedIDs(migrations.OperationMigrate, selection)
+	if err != nil {
+		return nil, err
+	}
+
+	migratable, err := m.selectDiscovery(ctx, discovery, selection)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Plan{
+		operation: migrations.OperationMigrate,
+		Resources: m.skipRancherMigrated(protect(migratable.WithDNs(), selection.IDs)),
+		confirmed: selection.IDs,
+	}, nil
+}
+
+func (m *Migration) PlanRollback(ctx context.Context, discovery migrations.Discovery, selection migrations.Selection) (migrations.Plan, error) {
+	err := checkSelectedIDs(migrations.OperationRollback, selection)
+	if err != nil {
+		return nil, err
+	}
+
+	migratable, err := m.selectDiscovery(ctx, discovery, selection)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Plan{
+		operation: migrations.OperationRollback,
+		Resources: protect(migratable.WithGUIDs(), selection.IDs),
+		confirmed: selection.IDs,
+	}, nil
+}
+
+func (m *Migration) Apply(ctx context.Context, plan migrations.Plan, opts migrations.ApplyOptions) error {
+	p, ok := plan.(*Plan)
+	if !ok {
+		return fmt.Errorf("invalid plan type %T", plan)
+	}
+
+	if opts.RunID == "" {
+		opts.RunID = migrations.NewRunID()
+	}
+	slog.Info("run started", "runID", opts.RunID, "operation", p.operation, "principals", len(p.Resources), "dryRun", opts.DryRun)
+
+	err := m.checkRancherMigration(ctx, p.operation)
+	if err != nil {
+		return err
+	}
+
+	if m.opts.RancherCompat {
+		annotateRancherMigration(p.Resources)
+	}
+
+	if opts.DryRun {
+		logging.Printf("Dry run of the %s: no resources will be updated\n", p.operation)
+
+		for i, res := range p.Resources {
+			logging.Printf("--- (%02d/%02d) %s ---\n", i+1, len(p.Resources), res.PrincipalID)
+
+			err := printDiffs(res, opts.RunID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if p.operation == migrations.OperationMigrate {
+		logging.Println("Start migration...")
+	} else {
+		logging.Println("Start rollback")
+	}
+
+	update := func() error {
+		if opts.Watch > 0 {
+			return m.updateAndWatch(ctx, p.operation, p.Resources, opts)
+		}
+		return updateResources(ctx, m.client, p.operation, p.Resources, opts)
+	}
+
+	if m.opts.SnapshotFile != "" {
+		next := update
+		update = func() error {
+			return withSnapshot(ctx, m.client, m.opts.SnapshotFile, p.operation, p.Resources, next)
+		}
+	}
+
+	if m.opts.RancherCompat {
+		return updateWithRancherStatus(ctx, m.client, update)
+	}
+
+	return update()
+}
+
+// NewResolver connects to the Active Directory servers configured in the activedirectory authconfig,
+// and to the additional Global Catalog and domain controllers of the options
+func NewResolver(ctx context.Context, c *client.RancherClient, opts *Options) (*LDAPResolver, error) {
+	adConfig := &apiv3.ActiveDirectoryConfig{}
+	err := c.Rancher.Get().Resource("authconfigs").Name(ad.Name).Do(ctx).Into(adConfig)
+	if err != nil {
+		return nil, fmt.Errorf("getting activedirectory authconfig: %w", err)
+	}
+
+	ldapConfig, err := client.NewLDAPConfigFromActiveDirectory(ctx, c.Kube.CoreV1(), adConfig, &opts.LDAP)
+	if err != nil {
+		return nil, fmt.Errorf("creating LDAPConfig from AD config: %w", err)
+	}
+	ldapConfig.FollowReferrals = opts.FollowReferrals
+	ldapConfig.MaxReferralHops = opts.MaxReferralHops
+
+	conn, err := client.NewLdapClient(ldapConfig)
+	if err != nil {
+		return nil, fmt.Errorf("creating LDAPConn from LDAPConfig: %w", err)
+	}
+
+	resolver := &LDAPResolver{
+		Conn:           conn,
+		Config:         adConfig,
+		RequireExactDN: opts.RequireExactDN,
+	}
+
+	if opts.GlobalCatalog || len(opts.GlobalCatalogServers) > 0 {
+		gcConfig := ldapConfig.GlobalCatalog(opts.GlobalCatalogServers)
+
+		gcConn, err := client.NewLdapClient(gcConfig)
+		if err != nil {
+			return nil, fmt.Errorf("creating LDAPConn to the Global Catalog: %w", err)
+		}
+
+		resolver.Targets = append(resolver.Targets, SearchTarget{
+			Name: fmt.Sprintf("%s:%d", strings.Join(gcConfig.Servers, ","), gcConfig.Port),
+			Conn: gcConn,
+		})
+	}
+
+	for _, searchBase := range opts.SearchBases {
+		if !strings.Contains(searchBase, "://") {
+			resolver.Targets = append(resolver.Targets, SearchTarget{
+				Name:       strings.Join(ldapConfig.Servers, ","),
+				Conn:       conn,
+				SearchBase: searchBase,
+			})
+			continue
+		}
+
+		dcConfig, baseDN, err := ldapConfig.ForURL(searchBase)
+		if err != nil {
+			return nil, err
+		}
+
+		dcConn, err := client.NewLdapClient(dcConfig)
+		if err != nil {
+			return nil, fmt.Errorf("creating LDAPConn to '%s': %w", searchBase, err)
+		}
+
+		resolver.Targets = append(resolver.Targets, SearchTarget{
+			Name:       fmt.Sprintf("%s:%d", dcConfig.Servers[0], dcConfig.Port),
+			Conn:       dcConn,
+			SearchBase: baseDN,
+		})
+	}
+
+	return resolver, nil
+}
+
+var _ migrations.Migration = &Migration{}
@@ -0,0 +1,100 @@
+package version_1_10_0
+
+import (
+	"context"
+	"testing"
+
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newADConfig(enabled bool) *apiv3.ActiveDirectoryConfig {
+	return &apiv3.ActiveDirectoryConfig{
+		AuthConfig: apiv3.AuthConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: ad.Name},
+			Type:       "activeDirectoryConfig",
+			Enabled:    enabled,
+			AccessMode: "unrestricted",
+		},
+		Servers:         []string{"dc1.example.com"},
+		UserObjectClass: "person",
+	}
+}
+
+func TestApplicable(t *testing.T) {
+	tests := []struct {
+		name          string
+		serverVersion string
+		adConfig      *apiv3.ActiveDirectoryConfig
+		want          bool
+	}{
+		{name: "minimum version", serverVersion: "v2.10.0", adConfig: newADConfig(true), want: true},
+		{name: "newer version", serverVersion: "v2.11.2", adConfig: newADConfig(true), want: true},
+		{name: "head build", serverVersion: "v2.10-head", adConfig: newADConfig(true), want: true},
+		{name: "development build", serverVersion: "dev", adConfig: newADConfig(true), want: true},
+		{name: "older version", serverVersion: "v2.9.3", adConfig: newADConfig(true), want: false},
+		{name: "activedirectory disabled", serverVersion: "v2.10.0", adConfig: newADConfig(false), want: false},
+		{name: "activedirectory not configured", serverVersion: "v2.10.0", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, c := newFakeRancher(t)
+			if tt.adConfig != nil {
+				f.add(t, "authconfigs", tt.adConfig)
+			}
+
+			got, err := (&Migration{}).Applicable(context.Background(), c, tt.serverVersion)
+			if err != nil {
+				t.Fatalf("Applicable() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Applicable(%s) = %v, want %v", tt.serverVersion, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplied(t *testing.T) {
+	const (
+		dnPrincipal   = "activedirectory_user://CN=john,DC=example,DC=com"
+		guidPrincipal = "activedirectory_user://objectGUID=00112233-4455-6677-8899-aabbccddeeff"
+	)
+
+	tests := []struct {
+		name          string
+		userPrincipal string
+		crtbPrincipal string
+		want          bool
+	}{
+		{name: "migrated", userPrincipal: guidPrincipal, crtbPrincipal: guidPrincipal, want: true},
+		{name: "user not migrated", userPrincipal: dnPrincipal, crtbPrincipal: guidPrincipal, want: false},
+		{name: "binding not migrated", userPrincipal: guidPrincipal, crtbPrincipal: dnPrincipal, want: false},
+		{name: "other providers only", userPrincipal: "local://u-john", crtbPrincipal: "local://u-john", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, c := newFakeRancher(t)
+			f.add(t, "users", &apiv3.User{
+				ObjectMeta:   metav1.ObjectMeta{Name: "u-john"},
+				PrincipalIDs: []string{"local://u-john", tt.userPrincipal},
+			})
+			f.add(t, "clusterroletemplatebindings", &apiv3.ClusterRoleTemplateBinding{
+				ObjectMeta:        metav1.ObjectMeta{Name: "crtb-john", Namespace: "c-1"},
+				ClusterName:       "c-1",
+				RoleTemplateName:  "cluster-member",
+				UserPrincipalName: tt.crtbPrincipal,
+			})
+
+			got, err := (&Migration{}).Applied(context.Background(), c)
+			if err != nil {
+				t.Fatalf("Applied() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Applied() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
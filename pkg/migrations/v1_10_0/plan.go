@@ -0,0 +1,239 @@
+package version_1_10_0
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+)
+
+// Plan is the list of principals to migrate or roll back
+type Plan struct {
+	operation string
+	Resources []*MigratableResource
+	// confirmed are the principal IDs explicitly selected, allowing the principals requiring a confirmation by policy
+	confirmed []string
+}
+
+func (p *Plan) Operation() string {
+	return p.operation
+}
+
+func (p *Plan) Print() {
+	for i, res := range p.Resources {
+		logging.Printf("%00d) %s\n", i+1, red(res.PrincipalID))
+		logging.Printf("\t-> %s\n", green(GetUpdatedPrincipalID(res)))
+		logging.Printf(
+			"\tUser: %t, ProjectRoleTemplateBindings: %d, ClusterRoleTemplateBindings: %d, Tokens: %d\n",
+			res.User != nil,
+			len(GetResourceByType[*PRTBResource](res.Bindings)),
+			len(GetResourceByType[*CRTBResource](res.Bindings)),
+			len(GetResourceByType[*TokenResource](res.Bindings)),
+		)
+	}
+}
+
+func (p *Plan) IDs() []string {
+	return principalIDs(p.Resources)
+}
+
+func (p *Plan) Counts() []migrations.Count {
+	counts := []migrations.Count{
+		{Kind: "principals", Count: len(p.Resources)},
+		{Kind: "Users"},
+		{Kind: "ProjectRoleTemplateBindings"},
+		{Kind: "ClusterRoleTemplateBindings"},
+		{Kind: "Tokens"},
+	}
+
+	for _, res := range p.Resources {
+		entry := NewSnapshotEntry(res)
+		if res.User != nil {
+			counts[1].Count++
+		}
+		counts[2].Count += entry.PRTBs
+		counts[3].Count += entry.CRTBs
+		counts[4].Count += entry.Tokens
+	}
+
+	return counts
+}
+
+func (p *Plan) Summary(id string) string {
+	res := p.resource(id)
+	if res == nil {
+		return id
+	}
+
+	user := "no user"
+	if res.User != nil {
+		user = fmt.Sprintf("user %s (%s)", res.User.Name, res.User.DisplayName)
+	}
+
+	entry := NewSnapshotEntry(res)
+	return fmt.Sprintf("%s, %s, PRTBs: %d, CRTBs: %d, Tokens: %d", res.PrincipalID, user, entry.PRTBs, entry.CRTBs, entry.Tokens)
+}
+
+func (p *Plan) PrintDetail(out io.Writer, id string) {
+	res := p.resource(id)
+	if res == nil {
+		return
+	}
+
+	fmt.Fprintf(out, "%s\n", blue(res.PrincipalID))
+	fmt.Fprintf(out, "\t-> %s\n", green(GetUpdatedPrincipalID(res)))
+	fmt.Fprintf(out, "\tDN:\t%s\n", res.DN)
+	fmt.Fprintf(out, "\tGUID:\t%s\n", res.GUID.UUID())
+	if !res.Match.Exact() {
+		fmt.Fprintf(out, "\tMatch:\t%s (DN not found, matched by %s)\n", yellow("fallback"), res.Match.Attribute)
+		fmt.Fprintf(out, "\tNew DN:\t%s\n", yellow(res.Match.DN))
+	}
+	fmt.Fprintf(out, "\tDomain:\t%s (%s)\n", res.Match.Domain, res.Match.Source)
+	printPolicy(out, res)
+	printResourceDetails(out, res)
+}
+
+func (p *Plan) Select(ids []string) migrations.Plan {
+	selected := &Plan{operation: p.operation, confirmed: p.confirmed}
+	for _, res := range p.Resources {
+		if slices.Contains(ids, res.PrincipalID) {
+			selected.Resources = append(selected.Resources, res)
+		}
+	}
+	return selected
+}
+
+func (p *Plan) resource(id string) *MigratableResource {
+	for _, res := range p.Resources {
+		if res.PrincipalID == id {
+			return res
+		}
+	}
+	return nil
+}
+
+// PlanFile is a saved Plan. It contains the DN and the objectGUID of every principal,
+// so that it can be applied without resolving the principals again (i.e. when created offline).
+type PlanFile struct {
+	Migration  string             `json:"migration"`
+	Operation  string             `json:"operation"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	Principals []PlannedPrincipal `json:"principals"`
+	// Confirmed are the principal IDs explicitly selected when the plan was created
+	Confirmed []string `json:"confirmed,omitempty"`
+}
+
+// PlannedPrincipal is a principal of a PlanFile
+type PlannedPrincipal struct {
+	PrincipalID        string `json:"principalID"`
+	UpdatedPrincipalID string `json:"updatedPrincipalID"`
+	DN                 string `json:"dn"`
+	GUID               string `json:"guid"`
+}
+
+// Save writes the Plan in the file
+func (p *Plan) Save(path string) error {
+	planFile := PlanFile{
+		Migration:  (&Migration{}).ID(),
+		Operation:  p.operation,
+		CreatedAt:  time.Now().UTC(),
+		Principals: []PlannedPrincipal{},
+		Confirmed:  p.confirmed,
+	}
+
+	for _, res := range p.Resources {
+		planFile.Principals = append(planFile.Principals, PlannedPrincipal{
+			PrincipalID:        res.PrincipalID,
+			UpdatedPrincipalID: GetUpdatedPrincipalID(res),
+			DN:                 res.DN,
+			GUID:               res.GUID.UUID(),
+		})
+	}
+
+	b, err := json.MarshalIndent(planFile, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o600)
+}
+
+// LoadPlan reads a saved Plan, and returns the Plan of the current resources of its principals.
+// The principals are resolved with the DNs and objectGUIDs of the file, without connecting to Active Directory.
+// The principals of the file without resources are skipped, since they were already updated.
+func (m *Migration) LoadPlan(ctx context.Context, path string) (migrations.Plan, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read plan file: %w", err)
+	}
+
+	planFile := &PlanFile{}
+	err = json.Unmarshal(b, planFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse plan file '%s': %w", path, err)
+	}
+
+	if planFile.Migration != m.ID() {
+		return nil, fmt.Errorf("plan file '%s' is for the %s migration", path, planFile.Migration)
+	}
+
+	entries := make([]DirectoryEntry, 0, len(planFile.Principals))
+	for _, principal := range planFile.Principals {
+		objectGUID, err := guid.Parse(principal.GUID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid objectGUID of '%s' in plan file: %w", principal.PrincipalID, err)
+		}
+		entries = append(entries, DirectoryEntry{DN: principal.DN, GUID: objectGUID})
+	}
+
+	resolver := NewDirectoryResolver(path, entries)
+	resolver.RequireExactDN = true
+
+	migratable, err := ListMigratableResources(ctx, m.client)
+	if err != nil {
+		return nil, err
+	}
+
+	planned := MigratableResources{}
+	var resources []*MigratableResource
+
+	for _, principal := range planFile.Principals {
+		res, found := migratable[principal.PrincipalID]
+		if !found {
+			slog.Warn("planned principal not found", "principal", principal.PrincipalID, "planFile", path)
+			logging.Printf("%s: principal %s not found, skipped\n", yellow("warning"), principal.PrincipalID)
+			continue
+		}
+
+		planned[principal.PrincipalID] = res
+		resources = append(resources, res)
+	}
+
+	err = planned.Resolve(resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	// the policy could have changed since the plan was created: it is applied again, allowing
+	// the principals requiring a confirmation only if they were explicitly selected in the plan
+	planned.applyPolicy(m.policy)
+
+	resources = protect(resources, planFile.Confirmed)
+	if planFile.Operation == migrations.OperationMigrate {
+		resources = m.skipRancherMigrated(resources)
+	}
+
+	return &Plan{
+		operation: planFile.Operation,
+		Resources: resources,
+		confirmed: planFile.Confirmed,
+	}, nil
+}
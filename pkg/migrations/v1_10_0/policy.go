@@ -0,0 +1,175 @@
+package version_1_10_0
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// PolicyExclude is the action of the principals never migrated nor rolled back
+	PolicyExclude = "exclude"
+	// PolicyConfirm is the action of the principals updated only when selected by their principal ID
+	PolicyConfirm = "confirm"
+)
+
+// Policy protects the principals that must not be updated automatically (i.e. break-glass admins), i.e.:
+//
+//	exclude:
+//	  usernames: [admin]
+//	  dnPatterns: ["CN=*,OU=Break Glass,DC=example,DC=com"]
+//	  clusters: [local]
+//	confirm:
+//	  principals: ["activedirectory_user://CN=svc-backup,OU=Service Accounts,DC=example,DC=com"]
+type Policy struct {
+	// Exclude are the principals never migrated nor rolled back
+	Exclude PolicyRules `json:"exclude"`
+	// Confirm are the principals updated only when explicitly selected by their principal ID
+	Confirm PolicyRules `json:"confirm"`
+}
+
+// PolicyRules match the principals of a Policy
+type PolicyRules struct {
+	// Principals are the exact principal IDs
+	Principals []string `json:"principals"`
+	// Usernames are the usernames or the names of the Rancher users
+	Usernames []string `json:"usernames"`
+	// DNPatterns are the case insensitive patterns of the DNs, with the syntax of path.Match (i.e. "CN=svc-*,OU=*")
+	DNPatterns []string `json:"dnPatterns"`
+	// Clusters are the IDs of the clusters: all the principals with bindings or tokens in them are matched
+	Clusters []string `json:"clusters"`
+}
+
+// PolicyMatch is the action of the Policy for a principal, and the rule that matched it
+type PolicyMatch struct {
+	Action string
+	Rule   string
+}
+
+// LoadPolicy reads the Policy file. Unknown fields are rejected, so that a typo cannot unprotect a principal.
+func LoadPolicy(path string) (*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read policy file: %w", err)
+	}
+
+	policy := &Policy{}
+	err = yaml.UnmarshalStrict(b, policy)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse policy file '%s': %w", path, err)
+	}
+
+	for _, pattern := range slices.Concat(policy.Exclude.DNPatterns, policy.Confirm.DNPatterns) {
+		if _, err := matchDN(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid DN pattern '%s' in policy file '%s': %w", pattern, path, err)
+		}
+	}
+
+	return policy, nil
+}
+
+// Match returns the action of the Policy for the principal. Exclusions take precedence over confirmations.
+func (p *Policy) Match(res *MigratableResource) PolicyMatch {
+	if p == nil {
+		return PolicyMatch{}
+	}
+
+	if rule := p.Exclude.match(res); rule != "" {
+		return PolicyMatch{Action: PolicyExclude, Rule: rule}
+	}
+	if rule := p.Confirm.match(res); rule != "" {
+		return PolicyMatch{Action: PolicyConfirm, Rule: rule}
+	}
+	return PolicyMatch{}
+}
+
+// match returns the description of the first rule matching the principal
+func (r PolicyRules) match(res *MigratableResource) string {
+	if slices.Contains(r.Principals, res.PrincipalID) {
+		return "principal " + res.PrincipalID
+	}
+
+	for _, username := range r.Usernames {
+		if hasUsername(res, username) {
+			return "username " + username
+		}
+	}
+
+	for _, pattern := range r.DNPatterns {
+		for _, dn := range []string{res.DN, res.Match.DN} {
+			if matched, _ := matchDN(pattern, dn); matched && dn != "" {
+				return "DN pattern " + pattern
+			}
+		}
+	}
+
+	for _, cluster := range r.Clusters {
+		if hasClusterBinding(res, cluster) {
+			return "cluster " + cluster
+		}
+	}
+
+	return ""
+}
+
+func matchDN(pattern, dn string) (bool, error) {
+	return path.Match(strings.ToLower(pattern), strings.ToLower(dn))
+}
+
+// applyPolicy sets the PolicyMatch of the principals
+func (u MigratableResources) applyPolicy(policy *Policy) {
+	for _, res := range u {
+		res.Policy = policy.Match(res)
+	}
+}
+
+// protect returns the resources that the Policy allows to update: the excluded principals are skipped,
+// as the ones requiring a confirmation that were not explicitly selected by their principal ID.
+// The users whose DN was not found, and that were matched by another attribute, always require a confirmation.
+func protect(resources []*MigratableResource, confirmed []string) []*MigratableResource {
+	allowed := make([]*MigratableResource, 0, len(resources))
+
+	for _, res := range resources {
+		switch {
+		case res.Policy.Action == PolicyExclude:
+			slog.Warn("principal excluded by policy", "principal", res.PrincipalID, "rule", res.Policy.Rule)
+			logging.Printf("%s: %s excluded by policy (%s)\n", yellow("skipped"), res.PrincipalID, res.Policy.Rule)
+
+		case res.Policy.Action == PolicyConfirm && !slices.Contains(confirmed, res.PrincipalID):
+			slog.Warn("principal requires confirmation", "principal", res.PrincipalID, "rule", res.Policy.Rule)
+			logging.Printf(
+				"%s: %s requires confirmation by policy (%s), select it by its principal ID to update it\n",
+				yellow("skipped"), res.PrincipalID, res.Policy.Rule,
+			)
+
+		case !res.Match.Exact() && !slices.Contains(confirmed, res.PrincipalID):
+			slog.Warn("principal requires confirmation", "principal", res.PrincipalID, "matchedBy", res.Match.Attribute)
+			logging.Printf(
+				"%s: %s DN not found and user matched by %s (%s), select it by its principal ID to update it\n",
+				yellow("skipped"), res.PrincipalID, res.Match.Attribute, res.Match.DN,
+			)
+
+		default:
+			allowed = append(allowed, res)
+		}
+	}
+
+	return allowed
+}
+
+// printPolicy prints the action of the Policy for the principal, if any
+func printPolicy(out io.Writer, res *MigratableResource) {
+	switch res.Policy.Action {
+	case PolicyExclude:
+		fmt.Fprintf(out, "\tPolicy:\t%s (%s)\n", red("excluded by policy"), res.Policy.Rule)
+	case PolicyConfirm:
+		fmt.Fprintf(out, "\tPolicy:\t%s (%s)\n", yellow("requires confirmation"), res.Policy.Rule)
+	}
+}
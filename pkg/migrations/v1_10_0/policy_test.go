@@ -0,0 +1,213 @@
+package version_1_10_0
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProtect(t *testing.T) {
+	const (
+		exact     = "activedirectory_user://CN=Exact,DC=example,DC=com"
+		excluded  = "activedirectory_user://CN=Excluded,DC=example,DC=com"
+		confirm   = "activedirectory_user://CN=Confirm,DC=example,DC=com"
+		fallback  = "activedirectory_user://CN=Moved,OU=Old,DC=example,DC=com"
+		confirmed = "activedirectory_user://CN=Confirmed,DC=example,DC=com"
+	)
+
+	output := logging.Output
+	logging.Output = io.Discard
+	t.Cleanup(func() { logging.Output = output })
+
+	resources := []*MigratableResource{
+		{PrincipalID: exact},
+		{PrincipalID: excluded, Policy: PolicyMatch{Action: PolicyExclude, Rule: "principal " + excluded}},
+		{PrincipalID: confirm, Policy: PolicyMatch{Action: PolicyConfirm, Rule: "principal " + confirm}},
+		{PrincipalID: fallback, Match: Match{DN: "CN=Moved,OU=New,DC=example,DC=com", Attribute: "sAMAccountName"}},
+		{PrincipalID: confirmed, Policy: PolicyMatch{Action: PolicyConfirm, Rule: "principal " + confirmed}},
+	}
+
+	tests := []struct {
+		name      string
+		confirmed []string
+		want      []string
+	}{
+		{
+			name: "nothing selected",
+			want: []string{exact},
+		},
+		{
+			name:      "selected by principal ID",
+			confirmed: []string{confirm, fallback, confirmed},
+			want:      []string{exact, confirm, fallback, confirmed},
+		},
+		{
+			name:      "excluded even if selected",
+			confirmed: []string{excluded},
+			want:      []string{exact},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, res := range protect(resources, tt.confirmed) {
+				got = append(got, res.PrincipalID)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("protect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			yaml: "exclude:\n  usernames: [admin]\n  dnPatterns: [\"CN=*,OU=Break Glass,DC=example,DC=com\"]\n" +
+				"confirm:\n  clusters: [local]\n",
+		},
+		{
+			name:    "unknown field",
+			yaml:    "exclude:\n  username: [admin]\n",
+			wantErr: "cannot parse policy file",
+		},
+		{
+			name:    "invalid DN pattern",
+			yaml:    "confirm:\n  dnPatterns: [\"CN=[svc,DC=example,DC=com\"]\n",
+			wantErr: "invalid DN pattern 'CN=[svc,DC=example,DC=com'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.yaml")
+			err := os.WriteFile(path, []byte(tt.yaml), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			policy, err := LoadPolicy(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadPolicy() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadPolicy() unexpected error: %v", err)
+			}
+			if !slices.Equal(policy.Exclude.Usernames, []string{"admin"}) || !slices.Equal(policy.Confirm.Clusters, []string{"local"}) {
+				t.Errorf("LoadPolicy() = %+v", policy)
+			}
+		})
+	}
+}
+
+func TestPolicyMatch(t *testing.T) {
+	policy := &Policy{
+		Exclude: PolicyRules{
+			Usernames:  []string{"admin"},
+			DNPatterns: []string{"CN=*,OU=Break Glass,DC=example,DC=com"},
+			Clusters:   []string{"local"},
+		},
+		Confirm: PolicyRules{
+			Principals: []string{"activedirectory_user://CN=svc-backup,OU=Service Accounts,DC=example,DC=com"},
+			DNPatterns: []string{"CN=svc-*,OU=Service Accounts,DC=example,DC=com"},
+			Usernames:  []string{"admin"},
+		},
+	}
+
+	// without a policy file all the principals are allowed
+	var noPolicy *Policy
+	if got := noPolicy.Match(&MigratableResource{PrincipalID: "activedirectory_user://CN=admin,DC=example,DC=com"}); got != (PolicyMatch{}) {
+		t.Errorf("Match() of a nil Policy = %+v, want no match", got)
+	}
+
+	tests := []struct {
+		name string
+		res  *MigratableResource
+		want PolicyMatch
+	}{
+		{
+			name: "not matched",
+			res: &MigratableResource{
+				PrincipalID: "activedirectory_user://CN=john,DC=example,DC=com",
+				DN:          "CN=john,DC=example,DC=com",
+			},
+			want: PolicyMatch{},
+		},
+		{
+			name: "exclusion takes precedence",
+			res: &MigratableResource{
+				PrincipalID: "activedirectory_user://CN=admin,DC=example,DC=com",
+				User:        &apiv3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-admin"}, Username: "admin"},
+			},
+			want: PolicyMatch{Action: PolicyExclude, Rule: "username admin"},
+		},
+		{
+			name: "DN pattern case insensitive",
+			res: &MigratableResource{
+				PrincipalID: "activedirectory_user://cn=jane,ou=break glass,dc=example,dc=com",
+				DN:          "cn=jane,ou=break glass,dc=example,dc=com",
+			},
+			want: PolicyMatch{Action: PolicyExclude, Rule: "DN pattern CN=*,OU=Break Glass,DC=example,DC=com"},
+		},
+		{
+			name: "DN pattern of the current DN",
+			res: &MigratableResource{
+				PrincipalID: "activedirectory_user://CN=jane,OU=Users,DC=example,DC=com",
+				DN:          "CN=jane,OU=Users,DC=example,DC=com",
+				Match:       Match{DN: "CN=jane,OU=Break Glass,DC=example,DC=com", Attribute: "sAMAccountName"},
+			},
+			want: PolicyMatch{Action: PolicyExclude, Rule: "DN pattern CN=*,OU=Break Glass,DC=example,DC=com"},
+		},
+		{
+			name: "cluster",
+			res: &MigratableResource{
+				PrincipalID: "activedirectory_user://CN=john,DC=example,DC=com",
+				Bindings: []PrincipalIDResource{&PRTBResource{PRTB: &apiv3.ProjectRoleTemplateBinding{
+					ProjectName: "local:p-1",
+				}}},
+			},
+			want: PolicyMatch{Action: PolicyExclude, Rule: "cluster local"},
+		},
+		{
+			name: "principal",
+			res: &MigratableResource{
+				PrincipalID: "activedirectory_user://CN=svc-backup,OU=Service Accounts,DC=example,DC=com",
+				DN:          "CN=svc-backup,OU=Service Accounts,DC=example,DC=com",
+			},
+			want: PolicyMatch{Action: PolicyConfirm, Rule: "principal activedirectory_user://CN=svc-backup,OU=Service Accounts,DC=example,DC=com"},
+		},
+		{
+			name: "confirm DN pattern",
+			res: &MigratableResource{
+				PrincipalID: "activedirectory_user://CN=svc-ci,OU=Service Accounts,DC=example,DC=com",
+				DN:          "CN=svc-ci,OU=Service Accounts,DC=example,DC=com",
+			},
+			want: PolicyMatch{Action: PolicyConfirm, Rule: "DN pattern CN=svc-*,OU=Service Accounts,DC=example,DC=com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Match(tt.res); got != tt.want {
+				t.Errorf("Match() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
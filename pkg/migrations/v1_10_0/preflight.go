@@ -0,0 +1,155 @@
+package version_1_10_0
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	"github.com/spf13/pflag"
+)
+
+// secretsNamespace is the namespace of the Secret of the service account password of the authconfigs
+const secretsNamespace = "cattle-global-data"
+
+// Permissions returns the access needed to read the authconfig, to update the users and their bindings,
+// to record the Events on the users, and to read (and optionally write) the status of Rancher's ad-guid-migration
+func (m *Migration) Permissions() []migrations.Permission {
+	group := apiv3.SchemeGroupVersion.Group
+
+	permissions := []migrations.Permission{
+		{Verb: "get", Group: group, Resource: "authconfigs"},
+		{Verb: "get", Resource: "secrets", Namespace: secretsNamespace},
+		{Verb: "get", Group: group, Resource: "settings"},
+		{Verb: "create", Resource: "events", Namespace: eventsNamespace},
+		{Verb: "get", Resource: "configmaps", Namespace: ad.StatusConfigMapNamespace},
+	}
+
+	if m.opts.Maintenance {
+		permissions = append(permissions, migrations.Permission{Verb: "patch", Group: group, Resource: "authconfigs"})
+	}
+
+	if m.opts.RancherCompat {
+		permissions = append(permissions,
+			migrations.Permission{Verb: "create", Resource: "configmaps", Namespace: ad.StatusConfigMapNamespace},
+			migrations.Permission{Verb: "update", Resource: "configmaps", Namespace: ad.StatusConfigMapNamespace},
+		)
+	}
+
+	for _, resourceVerbs := range []struct {
+		resource string
+		verbs    []string
+	}{
+		// watch is needed by migrate --watch
+		{"users", []string{"list", "watch", "update"}},
+		{"projectroletemplatebindings", []string{"list", "watch", "create", "delete"}},
+		{"clusterroletemplatebindings", []string{"list", "watch", "create", "delete"}},
+		{"tokens", []string{"list", "watch", "update"}},
+	} {
+		for _, verb := range resourceVerbs.verbs {
+			permissions = append(permissions, migrations.Permission{
+				Verb:     verb,
+				Group:    group,
+				Resource: resourceVerbs.resource,
+			})
+		}
+	}
+
+	return permissions
+}
+
+// Preflight checks the activedirectory authconfig, and for every configured server
+// the TLS certificate chain, the bind of the service account and a search of the user search base
+func (m *Migration) Preflight(ctx context.Context, c *client.RancherClient, fs *pflag.FlagSet) []migrations.CheckResult {
+	adConfig := &apiv3.ActiveDirectoryConfig{}
+	err := c.Rancher.Get().Resource("authconfigs").Name(ad.Name).Do(ctx).Into(adConfig)
+	results := []migrations.CheckResult{{Name: "activedirectory authconfig found", Err: err}}
+	if err != nil {
+		return results
+	}
+
+	if !adConfig.Enabled {
+		err = fmt.Errorf("the activedirectory auth provider is disabled")
+	}
+	results = append(results, migrations.CheckResult{Name: "activedirectory auth provider enabled", Err: err})
+
+	status, err := GetRancherMigrationStatus(ctx, c)
+	if err == nil && status == ad.StatusMigrationRunning {
+		err = fmt.Errorf("Rancher's ad-guid-migration is running")
+	}
+	results = append(results, migrations.CheckResult{Name: "Rancher's ad-guid-migration not running", Err: err})
+
+	err = nil
+	if value, found := adConfig.Annotations[MaintenanceAnnotation]; found {
+		err = fmt.Errorf("maintenance not restored (%s): restore it with the restore-maintenance command", value)
+	}
+	results = append(results, migrations.CheckResult{Name: "activedirectory authconfig not in maintenance", Err: err})
+
+	err = client.LoadLDAPFlags(fs)
+	if err != nil {
+		return append(results, migrations.CheckResult{Name: "LDAP flags loaded", Err: err})
+	}
+
+	ldapConfig, err := client.NewLDAPConfigFromActiveDirectory(ctx, c.Kube.CoreV1(), adConfig, &m.opts.LDAP)
+	results = append(results, migrations.CheckResult{Name: "LDAP configuration and service account password", Err: err})
+	if err != nil {
+		return results
+	}
+
+	for _, server := range ldapConfig.Servers {
+		results = append(results, checkServer(ldapConfig, server, adConfig.UserSearchBase)...)
+	}
+
+	return results
+}
+
+// checkServer checks the TLS, the bind and a search on a single server of the LDAPConfig
+func checkServer(ldapConfig *client.LDAPConfig, server, searchBase string) []migrations.CheckResult {
+	var results []migrations.CheckResult
+	address := fmt.Sprintf("%s:%d", server, ldapConfig.Port)
+
+	if ldapConfig.TLS || ldapConfig.StartTLS {
+		cert, err := client.VerifyTLS(ldapConfig, server)
+		name := fmt.Sprintf("TLS certificate chain of %s", address)
+		if err == nil {
+			name = fmt.Sprintf("%s (%s, expires %s)", name, cert.Subject.CommonName, cert.NotAfter.Format("2006-01-02"))
+		}
+		results = append(results, migrations.CheckResult{Name: name, Err: err})
+	}
+
+	serverConfig := *ldapConfig
+	serverConfig.Servers = []string{server}
+
+	conn, err := client.NewLdapClient(&serverConfig)
+	results = append(results, migrations.CheckResult{
+		Name: fmt.Sprintf("LDAP bind to %s as %s", address, ldapConfig.ServiceAccountName),
+		Err:  err,
+	})
+	if err != nil {
+		return results
+	}
+	defer conn.Close()
+
+	if searchBase == "" {
+		return results
+	}
+
+	search := ldapv3.NewSearchRequest(
+		searchBase,
+		ldapv3.ScopeBaseObject, ldapv3.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{"distinguishedName"},
+		nil,
+	)
+
+	_, err = conn.Search(search)
+	results = append(results, migrations.CheckResult{
+		Name: fmt.Sprintf("LDAP search of '%s' on %s", searchBase, address),
+		Err:  err,
+	})
+
+	return results
+}
@@ -0,0 +1,97 @@
+package version_1_10_0
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/spf13/pflag"
+)
+
+func TestPreflight(t *testing.T) {
+	inMaintenance := newADConfig(true)
+	inMaintenance.Annotations = map[string]string{MaintenanceAnnotation: `{"accessMode":"unrestricted","runId":"run-1"}`}
+
+	disabled := newADConfig(false)
+
+	tests := []struct {
+		name     string
+		adConfig *apiv3.ActiveDirectoryConfig
+		// wantFailed reports, for each check in order, whether it failed
+		wantFailed []bool
+	}{
+		{
+			name:       "authconfig not found",
+			wantFailed: []bool{true},
+		},
+		{
+			name:       "disabled",
+			adConfig:   disabled,
+			wantFailed: []bool{false, true, false, false, true},
+		},
+		{
+			name:       "in maintenance",
+			adConfig:   inMaintenance,
+			wantFailed: []bool{false, false, false, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, c := newFakeRancher(t)
+			if tt.adConfig != nil {
+				// the password Secret is missing, so that the LDAP servers are not checked
+				tt.adConfig.ServiceAccountPassword = "cattle-global-data:missing"
+				f.add(t, "authconfigs", tt.adConfig)
+			}
+
+			results := (&Migration{}).Preflight(context.Background(), c, pflag.NewFlagSet("test", pflag.ContinueOnError))
+
+			var failed []bool
+			for _, result := range results {
+				failed = append(failed, result.Err != nil)
+			}
+			if !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("Preflight() = %v, want failed %v", checkNames(results), tt.wantFailed)
+			}
+		})
+	}
+}
+
+func checkNames(results []migrations.CheckResult) []string {
+	var names []string
+	for _, result := range results {
+		name := result.Name
+		if result.Err != nil {
+			name += ": " + result.Err.Error()
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestPermissions(t *testing.T) {
+	hasPermission := func(permissions []migrations.Permission, verb, resource string) bool {
+		for _, permission := range permissions {
+			if permission.Verb == verb && permission.Resource == resource {
+				return true
+			}
+		}
+		return false
+	}
+
+	m := &Migration{}
+	if permissions := m.Permissions(); hasPermission(permissions, "patch", "authconfigs") || hasPermission(permissions, "update", "configmaps") {
+		t.Error("Permissions() include the permissions of the disabled options")
+	}
+
+	m.opts.Maintenance, m.opts.RancherCompat = true, true
+	permissions := m.Permissions()
+	for _, want := range [][2]string{{"patch", "authconfigs"}, {"update", "configmaps"}, {"watch", "users"}, {"delete", "clusterroletemplatebindings"}} {
+		if !hasPermission(permissions, want[0], want[1]) {
+			t.Errorf("Permissions() do not include %s %s", want[0], want[1])
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package version_1_10_0
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The labels and annotations of the objects updated by Rancher's own ad-guid-migration.
+// Rancher runs it at startup, until its status in the ad-guid-migration ConfigMap is finished,
+// reverting the principals in the objectGUID format to the DN format.
+const (
+	// rancherMigratedLabel is set to rancherMigratedValue on the objects updated by Rancher
+	rancherMigratedLabel = "ad-guid-migration"
+	rancherMigratedValue = "migrated"
+	// rancherMigrationDataAnnotation is the objectGUID of the principal of an object updated by Rancher
+	rancherMigrationDataAnnotation = "ad-guid-migration-data"
+	// rancherStatusLastUpdate is the field of the ConfigMap with the time of the last update of the status
+	rancherStatusLastUpdate = "statusLastUpdated"
+)
+
+// GetRancherMigrationStatus returns the status of Rancher's ad-guid-migration, empty if it never ran.
+// The migration is also running if the activedirectory authconfig is annotated as such.
+func GetRancherMigrationStatus(ctx context.Context, c *client.RancherClient) (string, error) {
+	adConfig := &apiv3.ActiveDirectoryConfig{}
+	err := c.Rancher.Get().Resource("authconfigs").Name(ad.Name).Do(ctx).Into(adConfig)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("getting activedirectory authconfig: %w", err)
+	}
+	if adConfig.Annotations[ad.StatusACMigrationRunning] == ad.StatusMigrationRunning {
+		return ad.StatusMigrationRunning, nil
+	}
+
+	cm, err := c.Kube.CoreV1().ConfigMaps(ad.StatusConfigMapNamespace).Get(ctx, ad.StatusConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("getting ad-guid-migration ConfigMap: %w", err)
+	}
+
+	return cm.Data[ad.StatusMigrationField], nil
+}
+
+// rancherMigrationFinished returns true if Rancher will not run its ad-guid-migration again at startup
+func rancherMigrationFinished(status string) bool {
+	switch status {
+	case ad.StatusMigrationFinished, ad.StatusMigrationFinishedWithSkipped, ad.StatusMigrationFinishedWithMissing:
+		return true
+	}
+	return false
+}
+
+// checkRancherMigration refuses to update the principals while Rancher's ad-guid-migration is running.
+// Before a migration it warns if Rancher could revert it at its next start.
+func (m *Migration) checkRancherMigration(ctx context.Context, operation string) error {
+	status, err := GetRancherMigrationStatus(ctx, m.client)
+	if err != nil {
+		return err
+	}
+	slog.Info("rancher ad-guid-migration status", "status", status)
+
+	if status == ad.StatusMigrationRunning {
+		return fmt.Errorf("Rancher's ad-guid-migration is running: wait for it to finish before the %s", operation)
+	}
+
+	if operation == migrations.OperationMigrate && !rancherMigrationFinished(status) && !m.opts.RancherCompat {
+		slog.Warn("rancher ad-guid-migration not finished", "status", status)
+		logging.Printf(
+			"%s: Rancher's ad-guid-migration is not finished (status '%s'): if it runs at the next start of Rancher "+
+				"it reverts the principals to the DN format, use --rancher-compat to mark it as finished\n",
+			yellow("warning"), status,
+		)
+	}
+
+	return nil
+}
+
+// setRancherMigrationStatus writes the status of Rancher's ad-guid-migration in its ConfigMap, creating it if needed
+func setRancherMigrationStatus(ctx context.Context, c *client.RancherClient, status string) error {
+	configMaps := c.Kube.CoreV1().ConfigMaps(ad.StatusConfigMapNamespace)
+
+	cm, err := configMaps.Get(ctx, ad.StatusConfigMapName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("getting ad-guid-migration ConfigMap: %w", err)
+	}
+	notFound := err != nil
+
+	if notFound {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ad.StatusConfigMapName,
+				Namespace: ad.StatusConfigMapNamespace,
+			},
+		}
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[ad.StatusMigrationField] = status
+	cm.Data[rancherStatusLastUpdate] = time.Now().UTC().Format(time.RFC3339)
+
+	if notFound {
+		_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+	} else {
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("cannot update ad-guid-migration ConfigMap: %w", err)
+	}
+
+	slog.Info("rancher ad-guid-migration status updated", "status", status)
+	return nil
+}
+
+// markedByRancher returns true if the user or any binding or token of the principal
+// was marked as migrated by Rancher's ad-guid-migration
+func markedByRancher(res *MigratableResource) bool {
+	if res.User != nil && res.User.Labels[rancherMigratedLabel] == rancherMigratedValue {
+		return true
+	}
+
+	for _, binding := range res.Bindings {
+		var labels map[string]string
+
+		switch b := binding.(type) {
+		case *PRTBResource:
+			labels = b.PRTB.Labels
+		case *CRTBResource:
+			labels = b.CRTB.Labels
+		case *TokenResource:
+			labels = b.Token.Labels
+		}
+
+		if labels[rancherMigratedLabel] == rancherMigratedValue {
+			return true
+		}
+	}
+
+	return false
+}
+
+// skipRancherMigrated returns the principals to migrate without the ones already marked as migrated
+// by Rancher's ad-guid-migration, unless they are explicitly included
+func (m *Migration) skipRancherMigrated(resources []*MigratableResource) []*MigratableResource {
+	if m.opts.IncludeRancherMigrated {
+		return resources
+	}
+
+	allowed := make([]*MigratableResource, 0, len(resources))
+
+	for _, res := range resources {
+		if !markedByRancher(res) {
+			allowed = append(allowed, res)
+			continue
+		}
+
+		slog.Warn("principal marked migrated by rancher", "principal", res.PrincipalID)
+		logging.Printf(
+			"%s: %s marked as migrated by Rancher's ad-guid-migration, use --include-rancher-migrated to migrate it\n",
+			yellow("skipped"), res.PrincipalID,
+		)
+	}
+
+	return allowed
+}
+
+// annotateRancherMigration sets on the objects of the principals the annotation of Rancher's ad-guid-migration
+// with their objectGUID. The migrated label is not set, so that the principals can still be rolled back.
+func annotateRancherMigration(resources []*MigratableResource) {
+	for _, res := range resources {
+		if res.GUID == nil {
+			continue
+		}
+		objectGUID := res.GUID.UUID()
+
+		objects := []metav1.Object{}
+		if res.User != nil {
+			objects = append(objects, res.User)
+		}
+		for _, binding := range res.Bindings {
+			switch b := binding.(type) {
+			case *PRTBResource:
+				objects = append(objects, b.PRTB)
+			case *CRTBResource:
+				objects = append(objects, b.CRTB)
+			case *TokenResource:
+				objects = append(objects, b.Token)
+			}
+		}
+
+		for _, obj := range objects {
+			annotations := obj.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[rancherMigrationDataAnnotation] = objectGUID
+			obj.SetAnnotations(annotations)
+		}
+	}
+}
+
+// updateWithRancherStatus updates the resources, and then sets the status of Rancher's ad-guid-migration
+// to finished, or to finished with skipped users if the update failed, so that Rancher does not revert
+// the updated principals at its next start. The status is not set to running during the update, as a killed
+// process would leave it running, blocking the next runs: the concurrent runs are prevented by the lock.
+func updateWithRancherStatus(ctx context.Context, c *client.RancherClient, update func() error) error {
+	updateErr := update()
+
+	status := ad.StatusMigrationFinished
+	if updateErr != nil {
+		status = ad.StatusMigrationFinishedWithSkipped
+	}
+
+	err := setRancherMigrationStatus(context.WithoutCancel(ctx), c, status)
+	if err != nil {
+		if updateErr != nil {
+			return fmt.Errorf("%w (%s)", updateErr, err)
+		}
+		return err
+	}
+
+	return updateErr
+}
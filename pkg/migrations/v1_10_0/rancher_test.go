@@ -0,0 +1,137 @@
+package version_1_10_0
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newStatusConfigMap(status string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ad.StatusConfigMapName, Namespace: ad.StatusConfigMapNamespace},
+		Data:       map[string]string{ad.StatusMigrationField: status},
+	}
+}
+
+func TestGetRancherMigrationStatus(t *testing.T) {
+	running := newADConfig(true)
+	running.Annotations = map[string]string{ad.StatusACMigrationRunning: ad.StatusMigrationRunning}
+
+	tests := []struct {
+		name      string
+		adConfig  *apiv3.ActiveDirectoryConfig
+		configMap *corev1.ConfigMap
+		want      string
+	}{
+		{
+			name: "never ran",
+			want: "",
+		},
+		{
+			name:      "status of the ConfigMap",
+			adConfig:  newADConfig(true),
+			configMap: newStatusConfigMap(ad.StatusMigrationFinishedWithMissing),
+			want:      ad.StatusMigrationFinishedWithMissing,
+		},
+		{
+			name:      "running annotation of the authconfig",
+			adConfig:  running,
+			configMap: newStatusConfigMap(ad.StatusMigrationFinished),
+			want:      ad.StatusMigrationRunning,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, c := newFakeRancher(t)
+			if tt.adConfig != nil {
+				f.add(t, "authconfigs", tt.adConfig)
+			}
+			if tt.configMap != nil {
+				f.add(t, "configmaps", tt.configMap)
+			}
+
+			status, err := GetRancherMigrationStatus(context.Background(), c)
+			if err != nil {
+				t.Fatalf("GetRancherMigrationStatus() error = %v", err)
+			}
+			if status != tt.want {
+				t.Errorf("GetRancherMigrationStatus() = %q, want %q", status, tt.want)
+			}
+
+			m := &Migration{client: c}
+			err = m.checkRancherMigration(context.Background(), migrations.OperationMigrate)
+			if (err != nil) != (tt.want == ad.StatusMigrationRunning) {
+				t.Errorf("checkRancherMigration() error = %v, want an error only while running", err)
+			}
+		})
+	}
+}
+
+func TestUpdateWithRancherStatus(t *testing.T) {
+	f, c := newFakeRancher(t)
+
+	// the ConfigMap is created
+	err := updateWithRancherStatus(context.Background(), c, func() error { return nil })
+	if err != nil {
+		t.Fatalf("updateWithRancherStatus() error = %v", err)
+	}
+
+	cm := &corev1.ConfigMap{}
+	f.get(t, "configmaps", ad.StatusConfigMapNamespace, ad.StatusConfigMapName, cm)
+	if cm.Data[ad.StatusMigrationField] != ad.StatusMigrationFinished || cm.Data[rancherStatusLastUpdate] == "" {
+		t.Errorf("ConfigMap data = %v, want %s", cm.Data, ad.StatusMigrationFinished)
+	}
+
+	// the ConfigMap is updated, also if the update fails or is interrupted
+	ctx, cancel := context.WithCancel(context.Background())
+	updateErr := errors.New("failed")
+	err = updateWithRancherStatus(ctx, c, func() error {
+		cancel()
+		return updateErr
+	})
+	if !errors.Is(err, updateErr) {
+		t.Fatalf("updateWithRancherStatus() error = %v, want %v", err, updateErr)
+	}
+
+	f.get(t, "configmaps", ad.StatusConfigMapNamespace, ad.StatusConfigMapName, cm)
+	if cm.Data[ad.StatusMigrationField] != ad.StatusMigrationFinishedWithSkipped {
+		t.Errorf("ConfigMap data = %v, want %s", cm.Data, ad.StatusMigrationFinishedWithSkipped)
+	}
+}
+
+func TestSkipRancherMigrated(t *testing.T) {
+	f, _ := newFakeRancher(t)
+
+	john := newTestUser(t, f, "u-john", "CN=john,DC=example,DC=com", "00112233-4455-6677-8899-aabbccddeeff")
+	jane := newTestUser(t, f, "u-jane", "CN=jane,DC=example,DC=com", "ffeeddcc-bbaa-9988-7766-554433221100")
+	GetResourceByType[*PRTBResource](jane.Bindings)[0].PRTB.Labels = map[string]string{rancherMigratedLabel: rancherMigratedValue}
+
+	resources := []*MigratableResource{john, jane}
+
+	m := &Migration{}
+	if got := m.skipRancherMigrated(resources); !slices.Equal(got, []*MigratableResource{john}) {
+		t.Errorf("skipRancherMigrated() = %v, want only %s", principalIDs(got), john.PrincipalID)
+	}
+
+	m.opts.IncludeRancherMigrated = true
+	if got := m.skipRancherMigrated(resources); !slices.Equal(got, resources) {
+		t.Errorf("skipRancherMigrated() = %v, want all the principals", principalIDs(got))
+	}
+
+	annotateRancherMigration(resources)
+	prtb := GetResourceByType[*PRTBResource](john.Bindings)[0].PRTB
+	if john.User.Annotations[rancherMigrationDataAnnotation] != john.GUID.UUID() || prtb.Annotations[rancherMigrationDataAnnotation] != john.GUID.UUID() {
+		t.Errorf("annotations = %v %v, want the objectGUID of john", john.User.Annotations, prtb.Annotations)
+	}
+	if john.User.Labels[rancherMigratedLabel] != "" {
+		t.Errorf("labels = %v, want not marked as migrated", john.User.Labels)
+	}
+}
@@ -0,0 +1,92 @@
+package version_1_10_0
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+)
+
+// ResolveIdentities translates every DN or objectGUID (in any of the encodings of ParseGUID, or as a principal ID),
+// and prints all the representations of the objectGUID and the principal IDs in both formats
+func (m *Migration) ResolveIdentities(ctx context.Context, identities []string) error {
+	resolver, err := m.getResolver(ctx)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+
+	for _, identity := range identities {
+		err := resolveIdentityEncodings(resolver, identity)
+		if err != nil {
+			failed++
+			slog.Error("cannot resolve identity", "identity", identity, "error", err)
+			logging.Printf("%s\n\t%s\n", blue(identity), red(err))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d identities not resolved", failed, len(identities))
+	}
+	return nil
+}
+
+func resolveIdentityEncodings(resolver Resolver, identity string) error {
+	value := strings.TrimPrefix(identity, ad.UserScope+"://")
+	value = strings.TrimPrefix(value, ad.ObjectGUIDAttribute+"=")
+
+	var (
+		dn, encoding string
+		objectGUID   guid.GUID
+		match        Match
+		err          error
+	)
+
+	// the objectGUID encodings are tried first, since a padded base64 is also a valid DN
+	objectGUID, encoding, err = ParseGUID(value)
+	switch {
+	case err == nil:
+		match, err = resolver.GetDN(objectGUID)
+		dn = match.DN
+	case isDN(value):
+		dn, encoding = value, "DN"
+		objectGUID, match, err = resolver.GetGUID(dn)
+	default:
+		return err
+	}
+	if err != nil && !errors.Is(err, errUserNotFound) {
+		return err
+	}
+
+	slog.Info("identity resolved", "identity", identity, "encoding", encoding, "dn", dn, "guid", objectGUID.UUID())
+
+	logging.Printf("%s (%s)\n", blue(identity), encoding)
+	logging.Printf("\tDN:\t\t%s\n", valueOrNotFound(dn))
+	if !match.Exact() {
+		logging.Printf("\tNew DN:\t\t%s (DN not found, matched by %s)\n", yellow(match.DN), match.Attribute)
+	}
+
+	if objectGUID == nil {
+		logging.Printf("\tobjectGUID:\t%s\n", red("not found"))
+		logging.Printf("\tPrincipal IDs:\t%s://%s\n", ad.UserScope, dn)
+		return nil
+	}
+
+	logging.Printf("\tUUID:\t\t%s\n", green(objectGUID.UUID()))
+	logging.Printf("\tByte-swapped:\t%s\n", ByteSwapped(objectGUID))
+	logging.Printf("\tBase64:\t\t%s\n", Base64(objectGUID))
+	logging.Printf("\tEscaped:\t%s\n", guid.Escape(objectGUID))
+	logging.Println("\tPrincipal IDs:")
+	if dn != "" {
+		logging.Printf("\t- %s://%s\n", ad.UserScope, dn)
+	}
+	logging.Printf("\t- %s://%s=%s\n", ad.UserScope, ad.ObjectGUIDAttribute, objectGUID.UUID())
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package version_1_10_0
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+)
+
+func TestResolveIdentityEncodings(t *testing.T) {
+	const dn = "CN=John Doe,OU=Users,DC=example,DC=com"
+
+	objectGUID, err := guid.Parse("00112233-4455-6677-8899-aabbccddeeff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := NewDirectoryResolver("test", []DirectoryEntry{{DN: dn, GUID: objectGUID}})
+
+	tests := []struct {
+		name         string
+		identity     string
+		wantEncoding string
+		wantErr      bool
+	}{
+		{name: "DN", identity: dn, wantEncoding: "DN"},
+		{name: "DN principal", identity: "activedirectory_user://" + dn, wantEncoding: "DN"},
+		{name: "uuid", identity: "00112233-4455-6677-8899-aabbccddeeff", wantEncoding: EncodingUUID},
+		{name: "objectGUID principal", identity: "activedirectory_user://objectGUID=00112233-4455-6677-8899-aabbccddeeff", wantEncoding: EncodingUUID},
+		{name: "base64 also parsable as a DN", identity: "MyIRAFVEd2aImaq7zN3u/w==", wantEncoding: EncodingBase64},
+		{name: "escaped", identity: `\33\22\11\00\55\44\77\66\88\99\aa\bb\cc\dd\ee\ff`, wantEncoding: EncodingEscaped},
+		{name: "neither objectGUID nor DN", identity: "john", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			output := logging.Output
+			logging.Output = &out
+			t.Cleanup(func() { logging.Output = output })
+
+			err := resolveIdentityEncodings(resolver, tt.identity)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveIdentityEncodings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := out.String()
+			if !strings.Contains(got, "("+tt.wantEncoding+")") {
+				t.Errorf("output does not report the encoding %s:\n%s", tt.wantEncoding, got)
+			}
+			if !strings.Contains(got, dn) || !strings.Contains(got, objectGUID.UUID()) {
+				t.Errorf("output does not report the DN and the objectGUID:\n%s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package version_1_10_0
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+	"github.com/rancher/rancher/pkg/auth/providers/common/ldap"
+)
+
+// fallbackAttributes are the attributes used to look up a user whose DN does not exist anymore
+var fallbackAttributes = []string{"sAMAccountName", "userPrincipalName"}
+
+var errUserNotFound = errors.New("user not found")
+
+// Resolver resolves the DN and the objectGUID of the Active Directory users
+type Resolver interface {
+	// GetGUID returns the objectGUID of the user with the provided DN
+	GetGUID(dn string) (guid.GUID, Match, error)
+	// GetDN returns the DN of the user with the provided objectGUID
+	GetDN(uuid guid.GUID) (Match, error)
+	// GetGUIDByAccountName returns the objectGUID of the user with the provided account name
+	GetGUIDByAccountName(name string) (guid.GUID, Match, error)
+	// GetGroupMembers returns the objectGUIDs of the users member of the group, also through nested groups
+	GetGroupMembers(groupDN string) ([]guid.GUID, error)
+}
+
+// LDAPResolver resolves the DN and the objectGUID of the Active Directory users
+type LDAPResolver struct {
+	Conn   *client.LdapClient
+	Config *apiv3.ActiveDirectoryConfig
+
+	// Targets are additional Global Catalogs or domain controllers searched
+	// when a user cannot be found in the UserSearchBase of the configured servers
+	Targets []SearchTarget
+
+	// RequireExactDN disables the lookup by account name of the users whose DN cannot be found
+	RequireExactDN bool
+}
+
+// SearchTarget is a connection to a directory server and the base DN to search into
+type SearchTarget struct {
+	// Name identifies the target in the output (i.e. the address of the server)
+	Name string
+	Conn *client.LdapClient
+	// SearchBase is the base DN of the subtree searches.
+	// An empty SearchBase will search the whole forest on a Global Catalog.
+	SearchBase string
+}
+
+// Match describes how an Active Directory user was found
+type Match struct {
+	// DN is the current DN of the user
+	DN string
+	// Attribute is the attribute that matched the RDN of the original DN.
+	// It is empty if the user was found with its exact DN.
+	Attribute string
+	// Domain is the DNS name of the domain of the user, derived from its DN
+	Domain string
+	// Source is the name of the SearchTarget where the user was found
+	Source string
+	// AccountName is the sAMAccountName of the user, if known
+	AccountName string
+}
+
+// Exact returns true if the user was found with its exact DN
+func (m Match) Exact() bool {
+	return m.Attribute == ""
+}
+
+func newMatch(dn, attribute string, target SearchTarget) Match {
+	return Match{
+		DN:        dn,
+		Attribute: attribute,
+		Domain:    domainFromDN(dn),
+		Source:    target.Name,
+	}
+}
+
+// GetGUID returns the objectGUID of the user with the provided DN.
+// If the DN does not exist anymore (i.e. the user was moved to another OU or renamed)
+// the user is searched by its account name, unless RequireExactDN is set.
+func (r *LDAPResolver) GetGUID(dn string) (guid.GUID, Match, error) {
+	for _, target := range r.targets() {
+		objectGUID, accountName, err := getGUID(target.Conn, r.Config, dn)
+		if err == nil {
+			match := newMatch(dn, "", target)
+			match.AccountName = accountName
+			return objectGUID, match, nil
+		}
+		if !isNotFound(err) {
+			return nil, Match{}, err
+		}
+	}
+
+	if r.RequireExactDN {
+		return nil, Match{}, fmt.Errorf("%w: no user with DN '%s'", errUserNotFound, dn)
+	}
+
+	for _, target := range r.targets() {
+		objectGUID, match, err := findGUIDByAccountName(target, r.Config, dn)
+		if err == nil {
+			return objectGUID, match, nil
+		}
+		if !isNotFound(err) {
+			return nil, Match{}, err
+		}
+	}
+
+	return nil, Match{}, fmt.Errorf("%w: no user with DN or account name matching '%s'", errUserNotFound, dn)
+}
+
+// GetGUIDByAccountName returns the objectGUID of the user with the provided
+// sAMAccountName, userPrincipalName or UserLoginAttribute
+func (r *LDAPResolver) GetGUIDByAccountName(name string) (guid.GUID, Match, error) {
+	for _, target := range r.targets() {
+		objectGUID, match, err := findGUIDByName(target, r.Config, name)
+		if err == nil {
+			return objectGUID, match, nil
+		}
+		if !isNotFound(err) {
+			return nil, Match{}, err
+		}
+	}
+
+	return nil, Match{}, fmt.Errorf("%w: no user with account name '%s'", errUserNotFound, name)
+}
+
+// GetDN returns the DN of the user with the provided objectGUID
+func (r *LDAPResolver) GetDN(uuid guid.GUID) (Match, error) {
+	for _, target := range r.targets() {
+		dn, accountName, err := getDN(target.Conn, r.Config, target.SearchBase, uuid)
+		if err == nil {
+			match := newMatch(dn, "", target)
+			match.AccountName = accountName
+			return match, nil
+		}
+		if !isNotFound(err) {
+			return Match{}, err
+		}
+	}
+
+	return Match{}, fmt.Errorf(
+		"LDAP search of user by objectGUID failed: %w: objectGUID '%s' not found",
+		errUserNotFound, uuid.UUID(),
+	)
+}
+
+// GetGroupMembers returns the objectGUIDs of the users member of the group, also through nested groups,
+// searching all the targets
+func (r *LDAPResolver) GetGroupMembers(groupDN string) ([]guid.GUID, error) {
+	// LDAP_MATCHING_RULE_IN_CHAIN matches the members of the nested groups
+	filter := fmt.Sprintf(
+		"(&(%v=%v)(%s:1.2.840.113556.1.4.1941:=%s))",
+		ad.ObjectClass, r.Config.UserObjectClass,
+		ad.MemberOfAttribute, ldapv3.EscapeFilter(groupDN),
+	)
+
+	var members []guid.GUID
+	seen := map[string]bool{}
+
+	for _, target := range r.targets() {
+		search := ldap.NewWholeSubtreeSearchRequest(target.SearchBase, filter, []string{ad.ObjectClass, "objectGUID"})
+
+		results, err := target.Conn.Search(search)
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("LDAP search of members of group '%s' failed: %w", groupDN, err)
+		}
+
+		for _, entry := range results.Entries {
+			objectGUID, err := guid.New(entry.GetRawAttributeValue("objectGUID"))
+			if err != nil {
+				return nil, fmt.Errorf("LDAP search of members of group '%s' failed: %w", groupDN, err)
+			}
+
+			if !seen[objectGUID.UUID()] {
+				seen[objectGUID.UUID()] = true
+				members = append(members, objectGUID)
+			}
+		}
+	}
+
+	return members, nil
+}
+
+// targets returns the configured servers with the UserSearchBase, followed by the additional targets
+func (r *LDAPResolver) targets() []SearchTarget {
+	primary := SearchTarget{
+		Name:       strings.Join(r.Config.Servers, ","),
+		Conn:       r.Conn,
+		SearchBase: r.Config.UserSearchBase,
+	}
+	return append([]SearchTarget{primary}, r.Targets...)
+}
+
+// getGUID returns the objectGUID and the sAMAccountName of the user with the DN
+func getGUID(lConn *client.LdapClient, config *apiv3.ActiveDirectoryConfig, dn string) (guid.GUID, string, error) {
+	search := ldap.NewBaseObjectSearchRequest(
+		dn,
+		fmt.Sprintf("(%v=%v)", ad.ObjectClass, config.UserObjectClass),
+		config.GetUserSearchAttributes(ad.MemberOfAttribute, ad.ObjectClass, "objectGUID", "sAMAccountName"),
+	)
+
+	results, err := lConn.Search(search)
+	if err != nil {
+		return nil, "", fmt.Errorf("LDAP search of user by DN failed: %w", err)
+	}
+
+	if len(results.Entries) == 0 {
+		return nil, "", fmt.Errorf("LDAP search of user by DN failed: %w: '%s'", errUserNotFound, dn)
+	}
+
+	objectGUID := results.Entries[0].GetRawAttributeValue("objectGUID")
+	parsedGuid, err := guid.New(objectGUID)
+	if err != nil {
+		return nil, "", fmt.Errorf("LDAP search of user by DN failed: %w", err)
+	}
+
+	return parsedGuid, results.Entries[0].GetAttributeValue("sAMAccountName"), nil
+}
+
+// getDN returns the DN and the sAMAccountName of the user with the objectGUID
+func getDN(lConn *client.LdapClient, config *apiv3.ActiveDirectoryConfig, searchBase string, uuid guid.GUID) (string, string, error) {
+	filter := fmt.Sprintf(
+		"(&(%v=%v)(%s=%s))",
+		ad.ObjectClass, config.UserObjectClass,
+		ad.ObjectGUIDAttribute, guid.Escape(uuid),
+	)
+
+	search := ldap.NewWholeSubtreeSearchRequest(
+		searchBase,
+		filter,
+		config.GetUserSearchAttributes(ad.MemberOfAttribute, ad.ObjectClass, "objectGUID", "sAMAccountName"),
+	)
+
+	results, err := lConn.Search(search)
+	if err != nil {
+		return "", "", fmt.Errorf("LDAP search of user by objectGUID failed: %w", err)
+	}
+
+	if len(results.Entries) == 0 {
+		return "", "", fmt.Errorf("LDAP search of user by objectGUID failed: %w: '%s'", errUserNotFound, uuid.UUID())
+	}
+
+	return results.Entries[0].DN, results.Entries[0].GetAttributeValue("sAMAccountName"), nil
+}
+
+// findGUIDByAccountName will search the target for a user whose sAMAccountName,
+// userPrincipalName or UserLoginAttribute matches the value of the first RDN of the DN
+func findGUIDByAccountName(target SearchTarget, config *apiv3.ActiveDirectoryConfig, dn string) (guid.GUID, Match, error) {
+	parsedDN, err := ldapv3.ParseDN(dn)
+	if err != nil {
+		return nil, Match{}, fmt.Errorf("cannot parse DN '%s': %w", dn, err)
+	}
+
+	if len(parsedDN.RDNs) == 0 || len(parsedDN.RDNs[0].Attributes) == 0 {
+		return nil, Match{}, fmt.Errorf("cannot get RDN from DN '%s'", dn)
+	}
+	name := parsedDN.RDNs[0].Attributes[0].Value
+
+	return findGUIDByName(target, config, name)
+}
+
+// findGUIDByName will search the target for a user whose sAMAccountName,
+// userPrincipalName or UserLoginAttribute matches the name
+func findGUIDByName(target SearchTarget, config *apiv3.ActiveDirectoryConfig, name string) (guid.GUID, Match, error) {
+	attributes := slices.Clone(fallbackAttributes)
+	if config.UserLoginAttribute != "" && !slices.Contains(attributes, config.UserLoginAttribute) {
+		attributes = append(attributes, config.UserLoginAttribute)
+	}
+
+	var nameFilter strings.Builder
+	for _, attr := range attributes {
+		nameFilter.WriteString(fmt.Sprintf("(%s=%s)", attr, ldapv3.EscapeFilter(name)))
+	}
+
+	filter := fmt.Sprintf(
+		"(&(%v=%v)(|%s))",
+		ad.ObjectClass, config.UserObjectClass,
+		nameFilter.String(),
+	)
+
+	search := ldap.NewWholeSubtreeSearchRequest(
+		target.SearchBase,
+		filter,
+		config.GetUserSearchAttributes(ad.ObjectClass, "objectGUID", "sAMAccountName", "userPrincipalName"),
+	)
+
+	results, err := target.Conn.Search(search)
+	if err != nil {
+		return nil, Match{}, fmt.Errorf("LDAP search of user by account name failed: %w", err)
+	}
+
+	if len(results.Entries) == 0 {
+		return nil, Match{}, fmt.Errorf("%w: no user with account name '%s'", errUserNotFound, name)
+	}
+	if len(results.Entries) > 1 {
+		return nil, Match{}, fmt.Errorf("user is ambiguous: %d users with account name '%s'", len(results.Entries), name)
+	}
+
+	entry := results.Entries[0]
+
+	parsedGuid, err := guid.New(entry.GetRawAttributeValue("objectGUID"))
+	if err != nil {
+		return nil, Match{}, fmt.Errorf("LDAP search of user by account name failed: %w", err)
+	}
+
+	matchedBy := strings.Join(attributes, "|")
+	for _, attr := range attributes {
+		if strings.EqualFold(entry.GetAttributeValue(attr), name) {
+			matchedBy = attr
+			break
+		}
+	}
+
+	match := newMatch(entry.DN, matchedBy, target)
+	match.AccountName = entry.GetAttributeValue("sAMAccountName")
+
+	return parsedGuid, match, nil
+}
+
+// isNotFound returns true if the error is caused by a DN or objectGUID not present in the searched directory
+func isNotFound(err error) bool {
+	return errors.Is(err, errUserNotFound) ||
+		ldapv3.IsErrorAnyOf(err, ldapv3.LDAPResultNoSuchObject, ldapv3.LDAPResultReferral)
+}
+
+// domainFromDN returns the DNS domain name from the DC components of the DN
+// (i.e. "CN=John,OU=Users,DC=child,DC=example,DC=com" returns "child.example.com")
+func domainFromDN(dn string) string {
+	parsedDN, err := ldapv3.ParseDN(dn)
+	if err != nil {
+		return ""
+	}
+
+	var dcs []string
+	for _, rdn := range parsedDN.RDNs {
+		for _, attr := range rdn.Attributes {
+			if strings.EqualFold(attr.Type, "DC") {
+				dcs = append(dcs, attr.Value)
+			}
+		}
+	}
+
+	return strings.Join(dcs, ".")
+}
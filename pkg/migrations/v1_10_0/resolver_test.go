@@ -0,0 +1,331 @@
+package version_1_10_0
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+)
+
+// fakeEntry is a user of a fakeDirectory
+type fakeEntry struct {
+	DN         string
+	Attributes map[string]string
+}
+
+// newFakeDirectory returns a connection to a fake LDAP server with the entries. The base object searches
+// return the entry with the DN, the subtree searches the entries in the base DN matching any of the equality
+// conditions of the filter, except for the objectClass.
+func newFakeDirectory(t *testing.T, entries ...fakeEntry) *client.LdapClient {
+	t.Helper()
+
+	conn, server := net.Pipe()
+	go func() {
+		defer server.Close()
+
+		for {
+			request, err := ber.ReadPacket(server)
+			if err != nil {
+				return
+			}
+			if len(request.Children) < 2 || request.Children[1].Tag != ldapv3.ApplicationSearchRequest {
+				continue
+			}
+
+			messageID := request.Children[0].Value.(int64)
+			search := request.Children[1]
+			baseDN, scope := search.Children[0].Value.(string), search.Children[1].Value.(int64)
+
+			resultCode := uint16(ldapv3.LDAPResultSuccess)
+			var found []fakeEntry
+
+			if scope == ldapv3.ScopeBaseObject {
+				resultCode = ldapv3.LDAPResultNoSuchObject
+				for _, entry := range entries {
+					if strings.EqualFold(entry.DN, baseDN) {
+						found, resultCode = append(found, entry), ldapv3.LDAPResultSuccess
+					}
+				}
+			} else {
+				conditions := equalityConditions(search.Children[6])
+				for _, entry := range entries {
+					if inBaseDN(entry.DN, baseDN) && entry.matches(conditions) {
+						found = append(found, entry)
+					}
+				}
+			}
+
+			for _, entry := range found {
+				server.Write(entry.packet(messageID).Bytes())
+			}
+			server.Write(searchResultDone(messageID, resultCode).Bytes())
+		}
+	}()
+
+	lConn := ldapv3.NewConn(conn, false)
+	lConn.Start()
+	t.Cleanup(func() { lConn.Close() })
+
+	return &client.LdapClient{Conn: lConn}
+}
+
+// equalityConditions returns the attribute values of the equality matches of the filter
+func equalityConditions(filter *ber.Packet) map[string][]byte {
+	conditions := map[string][]byte{}
+	if filter.ClassType == ber.ClassContext && filter.Tag == ldapv3.FilterEqualityMatch {
+		conditions[strings.ToLower(filter.Children[0].Value.(string))] = filter.Children[1].Data.Bytes()
+		return conditions
+	}
+
+	for _, child := range filter.Children {
+		for attribute, value := range equalityConditions(child) {
+			conditions[attribute] = value
+		}
+	}
+	return conditions
+}
+
+func inBaseDN(dn, baseDN string) bool {
+	if baseDN == "" {
+		return true
+	}
+	parsedBaseDN, _ := ldapv3.ParseDN(baseDN)
+	parsedDN, _ := ldapv3.ParseDN(dn)
+	return parsedBaseDN.AncestorOfFold(parsedDN)
+}
+
+func (e fakeEntry) matches(conditions map[string][]byte) bool {
+	for attribute, value := range e.Attributes {
+		condition, found := conditions[strings.ToLower(attribute)]
+		if !found || attribute == "objectClass" {
+			continue
+		}
+		if attribute == "objectGUID" && bytes.Equal(condition, []byte(value)) ||
+			attribute != "objectGUID" && strings.EqualFold(string(condition), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e fakeEntry) packet(messageID int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapv3.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "objectName"))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, value := range e.Attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		attribute.AppendChild(values)
+		attributes.AppendChild(attribute)
+	}
+	entry.AppendChild(attributes)
+	packet.AppendChild(entry)
+
+	return packet
+}
+
+func searchResultDone(messageID int64, resultCode uint16) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+
+	done := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapv3.ApplicationSearchResultDone, nil, "Search Result Done")
+	done.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+	done.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	done.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	packet.AppendChild(done)
+
+	return packet
+}
+
+func newUserEntry(t *testing.T, dn, uuid, accountName string) fakeEntry {
+	t.Helper()
+
+	objectGUID, err := guid.Parse(uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fakeEntry{
+		DN: dn,
+		Attributes: map[string]string{
+			"objectClass":       "person",
+			"objectGUID":        string(objectGUID),
+			"sAMAccountName":    accountName,
+			"userPrincipalName": accountName + "@example.com",
+		},
+	}
+}
+
+func TestLDAPResolverGetGUID(t *testing.T) {
+	const (
+		johnDN      = "CN=john,OU=Users,DC=example,DC=com"
+		johnMovedDN = "CN=john,OU=Sales,DC=example,DC=com"
+		johnGUID    = "00112233-4455-6677-8899-aabbccddeeff"
+	)
+
+	tests := []struct {
+		name           string
+		entries        []fakeEntry
+		dn             string
+		requireExactDN bool
+		wantDN         string
+		wantAttribute  string
+		wantNotFound   bool
+		wantErr        bool
+	}{
+		{
+			name:    "exact DN",
+			entries: []fakeEntry{newUserEntry(t, johnDN, johnGUID, "john")},
+			dn:      johnDN,
+			wantDN:  johnDN,
+		},
+		{
+			name:          "moved user found by sAMAccountName",
+			entries:       []fakeEntry{newUserEntry(t, johnMovedDN, johnGUID, "john")},
+			dn:            johnDN,
+			wantDN:        johnMovedDN,
+			wantAttribute: "sAMAccountName",
+		},
+		{
+			name:          "moved user found by userPrincipalName",
+			entries:       []fakeEntry{newUserEntry(t, "CN=John Doe,OU=Sales,DC=example,DC=com", johnGUID, "jdoe")},
+			dn:            "CN=jdoe@example.com,OU=Users,DC=example,DC=com",
+			wantDN:        "CN=John Doe,OU=Sales,DC=example,DC=com",
+			wantAttribute: "userPrincipalName",
+		},
+		{
+			name:           "moved user with exact DN required",
+			entries:        []fakeEntry{newUserEntry(t, johnMovedDN, johnGUID, "john")},
+			dn:             johnDN,
+			requireExactDN: true,
+			wantNotFound:   true,
+		},
+		{
+			name: "ambiguous account name",
+			entries: []fakeEntry{
+				newUserEntry(t, johnMovedDN, johnGUID, "john"),
+				newUserEntry(t, "CN=john,OU=Marketing,DC=example,DC=com", "ffeeddcc-bbaa-9988-7766-554433221100", "john"),
+			},
+			dn:      johnDN,
+			wantErr: true,
+		},
+		{
+			name:         "not found",
+			entries:      []fakeEntry{newUserEntry(t, "CN=jane,OU=Users,DC=example,DC=com", johnGUID, "jane")},
+			dn:           johnDN,
+			wantNotFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &LDAPResolver{
+				Conn:           newFakeDirectory(t, tt.entries...),
+				Config:         &apiv3.ActiveDirectoryConfig{Servers: []string{"dc1.example.com"}, UserObjectClass: "person", UserSearchBase: "DC=example,DC=com"},
+				RequireExactDN: tt.requireExactDN,
+			}
+
+			objectGUID, match, err := resolver.GetGUID(tt.dn)
+			if tt.wantNotFound || tt.wantErr {
+				if err == nil || errors.Is(err, errUserNotFound) != tt.wantNotFound {
+					t.Fatalf("GetGUID() error = %v, want not found %v", err, tt.wantNotFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetGUID() error = %v", err)
+			}
+
+			if objectGUID.UUID() != johnGUID {
+				t.Errorf("GetGUID() = %s, want %s", objectGUID.UUID(), johnGUID)
+			}
+			if match.DN != tt.wantDN || match.Attribute != tt.wantAttribute || match.Exact() != (tt.wantAttribute == "") {
+				t.Errorf("GetGUID() match = %+v, want DN %s matched by '%s'", match, tt.wantDN, tt.wantAttribute)
+			}
+		})
+	}
+}
+
+func TestLDAPResolverTargets(t *testing.T) {
+	const (
+		johnDN   = "CN=john,OU=Users,DC=example,DC=com"
+		janeDN   = "CN=jane,OU=Users,DC=child,DC=example,DC=com"
+		janeGUID = "ffeeddcc-bbaa-9988-7766-554433221100"
+	)
+
+	john := newUserEntry(t, johnDN, "00112233-4455-6677-8899-aabbccddeeff", "john")
+	jane := newUserEntry(t, janeDN, janeGUID, "jane")
+
+	resolver := &LDAPResolver{
+		// the configured domain controller has only the users of its domain
+		Conn:   newFakeDirectory(t, john),
+		Config: &apiv3.ActiveDirectoryConfig{Servers: []string{"dc1.example.com"}, UserObjectClass: "person", UserSearchBase: "DC=example,DC=com"},
+		Targets: []SearchTarget{
+			{Name: "gc.example.com:3268", Conn: newFakeDirectory(t, john, jane)},
+		},
+	}
+
+	objectGUID, match, err := resolver.GetGUID(janeDN)
+	if err != nil {
+		t.Fatalf("GetGUID() error = %v", err)
+	}
+	if objectGUID.UUID() != janeGUID || !match.Exact() || match.Source != "gc.example.com:3268" || match.Domain != "child.example.com" {
+		t.Errorf("GetGUID() = %s, %+v, want %s found in the Global Catalog", objectGUID.UUID(), match, janeGUID)
+	}
+
+	janeObjectGUID, _ := guid.Parse(janeGUID)
+	match, err = resolver.GetDN(janeObjectGUID)
+	if err != nil {
+		t.Fatalf("GetDN() error = %v", err)
+	}
+	if match.DN != janeDN || match.Source != "gc.example.com:3268" {
+		t.Errorf("GetDN() = %+v, want %s found in the Global Catalog", match, janeDN)
+	}
+
+	_, match, err = resolver.GetGUID(johnDN)
+	if err != nil {
+		t.Fatalf("GetGUID() error = %v", err)
+	}
+	if match.Source != "dc1.example.com" || match.Domain != "example.com" {
+		t.Errorf("GetGUID() = %+v, want found in the configured servers", match)
+	}
+
+	_, err = resolver.GetDN(guid.GUID(bytes.Repeat([]byte{1}, 16)))
+	if !errors.Is(err, errUserNotFound) {
+		t.Errorf("GetDN() error = %v, want %v", err, errUserNotFound)
+	}
+}
+
+func TestDomainFromDN(t *testing.T) {
+	tests := []struct {
+		dn   string
+		want string
+	}{
+		{dn: "CN=John,OU=Users,DC=example,DC=com", want: "example.com"},
+		{dn: "CN=John,OU=Users,DC=child,DC=example,DC=com", want: "child.example.com"},
+		{dn: "cn=John,dc=Example,dc=com", want: "Example.com"},
+		{dn: "CN=John,OU=Users", want: ""},
+		{dn: "not a DN", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dn, func(t *testing.T) {
+			if got := domainFromDN(tt.dn); got != tt.want {
+				t.Errorf("domainFromDN() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
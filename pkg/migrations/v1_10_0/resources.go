@@ -84,12 +84,43 @@ func (u MigratableResources) WithGUIDs() []*MigratableResource {
 	return uuids
 }
 
+// Pending returns the principal IDs still in the DN format
+func (u MigratableResources) Pending() []string {
+	return principalIDs(u.WithDNs())
+}
+
+// Migrated returns the principal IDs already in the objectGUID format
+func (u MigratableResources) Migrated() []string {
+	return principalIDs(u.WithGUIDs())
+}
+
+// PrincipalIDs returns all the principal IDs, sorted
+func (u MigratableResources) PrincipalIDs() []string {
+	ids := make([]string, 0, len(u))
+	for principalID := range u {
+		ids = append(ids, principalID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func principalIDs(resources []*MigratableResource) []string {
+	ids := make([]string, 0, len(resources))
+	for _, res := range resources {
+		ids = append(ids, res.PrincipalID)
+	}
+	return ids
+}
+
 type MigratableResource struct {
 	User        *apiv3.User
 	PrincipalID string
 	DN          string
 	GUID        guid.GUID
+	Match       Match
 	Bindings    []PrincipalIDResource
+	// Policy is the action of the Policy for the principal
+	Policy PolicyMatch
 }
 
 func (u *MigratableResource) UpdatePrincipalID(updated string) bool {
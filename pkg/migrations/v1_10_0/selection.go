@@ -0,0 +1,216 @@
+package version_1_10_0
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+)
+
+// selectDiscovery returns the discovered resources of the principals of the Selection.
+// Every value of the Selection must match at least one discovered principal.
+func (m *Migration) selectDiscovery(ctx context.Context, discovery migrations.Discovery, selection migrations.Selection) (MigratableResources, error) {
+	migratable, ok := discovery.(MigratableResources)
+	if !ok {
+		return nil, fmt.Errorf("invalid discovery type %T", discovery)
+	}
+
+	if selection.Empty() {
+		return migratable, nil
+	}
+
+	s := &selector{
+		migratable: migratable,
+		selected:   MigratableResources{},
+	}
+	for principalID, res := range migratable {
+		s.selected[principalID] = res
+	}
+
+	s.filter("principal", selection.IDs, func(res *MigratableResource, id string) bool {
+		return res.PrincipalID == id
+	})
+	s.filter("--user", selection.Users, hasUsername)
+	s.filter("--cluster", selection.Clusters, hasClusterBinding)
+	s.filter("--project", selection.Projects, hasProjectBinding)
+	s.filter("--namespace", selection.Namespaces, hasNamespaceBinding)
+	s.filter("--ou", selection.OUs, inOU)
+
+	if len(selection.Groups) > 0 {
+		resolver, err := m.getResolver(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		members := map[string]map[string]bool{}
+		for _, group := range selection.Groups {
+			groupDN := strings.TrimPrefix(group, ad.GroupScope+"://")
+
+			guids, err := resolver.GetGroupMembers(groupDN)
+			if err != nil {
+				return nil, err
+			}
+
+			members[group] = map[string]bool{}
+			for _, objectGUID := range guids {
+				members[group][objectGUID.UUID()] = true
+			}
+		}
+
+		s.filter("--ad-group", selection.Groups, func(res *MigratableResource, group string) bool {
+			return res.GUID != nil && members[group][res.GUID.UUID()]
+		})
+	}
+
+	for _, excluded := range selection.Exclude {
+		found := false
+		for principalID, res := range migratable {
+			if res.PrincipalID == excluded || hasUsername(res, excluded) {
+				found = true
+				delete(s.selected, principalID)
+			}
+		}
+		if !found {
+			s.errs = append(s.errs, fmt.Errorf("--exclude '%s' does not match any principal", excluded))
+		}
+	}
+
+	if len(s.errs) > 0 {
+		return nil, fmt.Errorf("invalid selection:\n%w", errors.Join(s.errs...))
+	}
+
+	return s.selected, nil
+}
+
+// selector narrows the selected principals with the selectors, collecting the values that don't match
+type selector struct {
+	migratable MigratableResources
+	selected   MigratableResources
+	errs       []error
+}
+
+// filter keeps the selected principals matching any of the values. The values are matched against all
+// the discovered principals, so that a value not matching any of them is reported.
+func (s *selector) filter(name string, values []string, matches func(res *MigratableResource, value string) bool) {
+	if len(values) == 0 {
+		return
+	}
+
+	filtered := MigratableResources{}
+
+	for _, value := range values {
+		found := false
+		for principalID, res := range s.migratable {
+			if !matches(res, value) {
+				continue
+			}
+
+			found = true
+			if _, selected := s.selected[principalID]; selected {
+				filtered[principalID] = res
+			}
+		}
+
+		if !found {
+			s.errs = append(s.errs, fmt.Errorf("%s '%s' does not match any principal", name, value))
+		}
+	}
+
+	s.selected = filtered
+}
+
+// hasUsername returns true if the principal has a User with the username or the name
+func hasUsername(res *MigratableResource, username string) bool {
+	return res.User != nil && (res.User.Username == username || res.User.Name == username)
+}
+
+// hasClusterBinding returns true if the principal has a binding or a token of the cluster
+func hasClusterBinding(res *MigratableResource, clusterID string) bool {
+	for _, binding := range res.Bindings {
+		switch b := binding.(type) {
+		case *CRTBResource:
+			if b.CRTB.ClusterName == clusterID {
+				return true
+			}
+		case *PRTBResource:
+			if cluster, _, _ := strings.Cut(b.PRTB.ProjectName, ":"); cluster == clusterID {
+				return true
+			}
+		case *TokenResource:
+			if b.Token.ClusterName == clusterID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasProjectBinding returns true if the principal has a binding of the project,
+// identified by "<cluster>:<project>" or only by "<project>"
+func hasProjectBinding(res *MigratableResource, projectID string) bool {
+	for _, prtb := range GetResourceByType[*PRTBResource](res.Bindings) {
+		_, project, _ := strings.Cut(prtb.PRTB.ProjectName, ":")
+		if prtb.PRTB.ProjectName == projectID || project == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNamespaceBinding returns true if the principal has a binding in the namespace
+func hasNamespaceBinding(res *MigratableResource, namespace string) bool {
+	for _, binding := range res.Bindings {
+		switch b := binding.(type) {
+		case *CRTBResource:
+			if b.CRTB.Namespace == namespace {
+				return true
+			}
+		case *PRTBResource:
+			if b.PRTB.Namespace == namespace {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inOU returns true if the DN of the principal is in the subtree of the OU.
+// The DNs are compared by their attributes, case insensitively.
+func inOU(res *MigratableResource, ou string) bool {
+	parsedOU, err := ldapv3.ParseDN(strings.TrimSpace(ou))
+	if err != nil || len(parsedOU.RDNs) == 0 {
+		return false
+	}
+
+	parsedDN, err := ldapv3.ParseDN(res.DN)
+	if err != nil {
+		return false
+	}
+	return parsedOU.AncestorOfFold(parsedDN)
+}
+
+// checkSelectedIDs returns an error for every principal ID of the Selection that the operation
+// cannot update: only the DN principals are migrated, and only the objectGUID principals rolled back.
+func checkSelectedIDs(operation string, selection migrations.Selection) error {
+	var errs []error
+
+	for _, id := range selection.IDs {
+		migrated := strings.Contains(id, ad.ObjectGUIDAttribute)
+
+		switch {
+		case operation == migrations.OperationMigrate && migrated:
+			errs = append(errs, fmt.Errorf("principal '%s' is already migrated: it can only be rolled back", id))
+		case operation == migrations.OperationRollback && !migrated:
+			errs = append(errs, fmt.Errorf("principal '%s' is not migrated: it can only be migrated", id))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid selection:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package version_1_10_0
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInOU(t *testing.T) {
+	const dn = "CN=John Doe,OU=Sales,OU=EMEA,DC=example,DC=com"
+
+	tests := []struct {
+		name string
+		ou   string
+		want bool
+	}{
+		{name: "parent OU", ou: "OU=Sales,OU=EMEA,DC=example,DC=com", want: true},
+		{name: "ancestor OU", ou: "OU=EMEA,DC=example,DC=com", want: true},
+		{name: "domain", ou: "DC=example,DC=com", want: true},
+		{name: "different case", ou: "ou=sales,ou=emea,dc=EXAMPLE,dc=com", want: true},
+		{name: "spaces around the separators", ou: " OU=Sales, OU=EMEA, DC=example, DC=com ", want: true},
+		{name: "different OU", ou: "OU=Marketing,OU=EMEA,DC=example,DC=com", want: false},
+		{name: "partial RDN", ou: "Sales,OU=EMEA,DC=example,DC=com", want: false},
+		{name: "the DN itself", ou: dn, want: false},
+		{name: "empty", ou: "", want: false},
+		{name: "invalid", ou: "not a DN", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inOU(&MigratableResource{DN: dn}, tt.ou); got != tt.want {
+				t.Errorf("inOU(%q) = %v, want %v", tt.ou, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSelectedIDs(t *testing.T) {
+	const (
+		dnPrincipal   = "activedirectory_user://CN=John Doe,DC=example,DC=com"
+		guidPrincipal = "activedirectory_user://objectGUID=00112233-4455-6677-8899-aabbccddeeff"
+	)
+
+	tests := []struct {
+		name      string
+		operation string
+		ids       []string
+		wantErr   bool
+	}{
+		{name: "migrate DN principal", operation: migrations.OperationMigrate, ids: []string{dnPrincipal}},
+		{name: "migrate objectGUID principal", operation: migrations.OperationMigrate, ids: []string{dnPrincipal, guidPrincipal}, wantErr: true},
+		{name: "rollback objectGUID principal", operation: migrations.OperationRollback, ids: []string{guidPrincipal}},
+		{name: "rollback DN principal", operation: migrations.OperationRollback, ids: []string{guidPrincipal, dnPrincipal}, wantErr: true},
+		{name: "no principals", operation: migrations.OperationRollback},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSelectedIDs(tt.operation, migrations.Selection{IDs: tt.ids})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkSelectedIDs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSelectDiscovery(t *testing.T) {
+	const (
+		john  = "activedirectory_user://CN=John,OU=Sales,DC=example,DC=com"
+		jane  = "activedirectory_user://CN=Jane,OU=Sales,DC=example,DC=com"
+		admin = "activedirectory_user://CN=Admin,OU=IT,DC=example,DC=com"
+	)
+
+	discovery := MigratableResources{
+		john: {
+			PrincipalID: john,
+			DN:          "CN=John,OU=Sales,DC=example,DC=com",
+			User:        &apiv3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-john"}, Username: "john"},
+			Bindings: []PrincipalIDResource{
+				&PRTBResource{PRTB: &apiv3.ProjectRoleTemplateBinding{ProjectName: "c-1:p-1"}},
+			},
+		},
+		jane: {
+			PrincipalID: jane,
+			DN:          "CN=Jane,OU=Sales,DC=example,DC=com",
+			Bindings: []PrincipalIDResource{
+				&CRTBResource{CRTB: &apiv3.ClusterRoleTemplateBinding{ClusterName: "c-2"}},
+			},
+		},
+		admin: {
+			PrincipalID: admin,
+			DN:          "CN=Admin,OU=IT,DC=example,DC=com",
+			User:        &apiv3.User{ObjectMeta: metav1.ObjectMeta{Name: "u-admin"}, Username: "admin"},
+			Bindings: []PrincipalIDResource{
+				&CRTBResource{CRTB: &apiv3.ClusterRoleTemplateBinding{ClusterName: "c-1"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		selection migrations.Selection
+		want      []string
+		wantErr   bool
+	}{
+		{name: "empty selection", want: []string{admin, jane, john}},
+		{name: "principal", selection: migrations.Selection{IDs: []string{jane}}, want: []string{jane}},
+		{name: "user by username or name", selection: migrations.Selection{Users: []string{"john", "u-admin"}}, want: []string{admin, john}},
+		{name: "cluster from CRTB and PRTB", selection: migrations.Selection{Clusters: []string{"c-1"}}, want: []string{admin, john}},
+		{name: "project without cluster", selection: migrations.Selection{Projects: []string{"p-1"}}, want: []string{john}},
+		{name: "OU", selection: migrations.Selection{OUs: []string{"ou=sales,dc=example,dc=com"}}, want: []string{jane, john}},
+		{name: "selectors are intersected", selection: migrations.Selection{Clusters: []string{"c-1"}, OUs: []string{"OU=Sales,DC=example,DC=com"}}, want: []string{john}},
+		{name: "exclude by username", selection: migrations.Selection{Clusters: []string{"c-1"}, Exclude: []string{"admin"}}, want: []string{john}},
+		{name: "unknown principal", selection: migrations.Selection{IDs: []string{"activedirectory_user://CN=Unknown"}}, wantErr: true},
+		{name: "unknown OU", selection: migrations.Selection{OUs: []string{"OU=Marketing,DC=example,DC=com"}}, wantErr: true},
+		{name: "unknown exclude", selection: migrations.Selection{Users: []string{"john"}, Exclude: []string{"unknown"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := (&Migration{}).selectDiscovery(context.Background(), discovery, tt.selection)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectDiscovery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for principalID := range selected {
+				got = append(got, principalID)
+			}
+			slices.Sort(got)
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("selectDiscovery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
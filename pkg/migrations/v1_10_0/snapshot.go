@@ -0,0 +1,169 @@
+package version_1_10_0
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+)
+
+// Snapshot holds the number of resources of the migrated principals before their migration,
+// keyed by the updated principal ID. It is used to verify that no resources were lost.
+type Snapshot map[string]SnapshotEntry
+
+// The status of a SnapshotEntry. The entries are planned before the update, and applied after it
+// if their principal was migrated. The entries without a status are applied.
+const (
+	SnapshotPlanned = "planned"
+	SnapshotApplied = "applied"
+)
+
+// SnapshotEntry is the footprint of a principal before its migration
+type SnapshotEntry struct {
+	PrincipalID string    `json:"principalID"`
+	User        string    `json:"user,omitempty"`
+	PRTBs       int       `json:"prtbs"`
+	CRTBs       int       `json:"crtbs"`
+	Tokens      int       `json:"tokens"`
+	TakenAt     time.Time `json:"takenAt"`
+	Status      string    `json:"status,omitempty"`
+}
+
+// Applied returns true if the principal of the entry was migrated
+func (e SnapshotEntry) Applied() bool {
+	return e.Status != SnapshotPlanned
+}
+
+// NewSnapshotEntry returns the SnapshotEntry of the current resources of a principal
+func NewSnapshotEntry(res *MigratableResource) SnapshotEntry {
+	entry := SnapshotEntry{
+		PrincipalID: res.PrincipalID,
+		PRTBs:       len(GetResourceByType[*PRTBResource](res.Bindings)),
+		CRTBs:       len(GetResourceByType[*CRTBResource](res.Bindings)),
+		Tokens:      len(GetResourceByType[*TokenResource](res.Bindings)),
+		TakenAt:     time.Now().UTC(),
+	}
+
+	if res.User != nil {
+		entry.User = res.User.Name
+	}
+
+	return entry
+}
+
+// LoadSnapshot reads the Snapshot saved in the file. A missing file returns a nil Snapshot.
+func LoadSnapshot(path string) (Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot file: %w", err)
+	}
+
+	snapshot := Snapshot{}
+	err = json.Unmarshal(b, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse snapshot file '%s': %w", path, err)
+	}
+
+	return snapshot, nil
+}
+
+// Save writes the Snapshot in the file
+func (s Snapshot) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o600)
+}
+
+// planSnapshot adds the principals to be migrated to the snapshot file, as planned
+func planSnapshot(path string, resources []*MigratableResource) error {
+	snapshot, err := LoadSnapshot(path)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		snapshot = Snapshot{}
+	}
+
+	for _, res := range resources {
+		entry := NewSnapshotEntry(res)
+		entry.Status = SnapshotPlanned
+		snapshot[GetUpdatedPrincipalID(res)] = entry
+	}
+
+	return snapshot.Save(path)
+}
+
+// completeSnapshot marks as applied the migrated principals of the snapshot file, and removes the rolled back ones.
+// A principal is updated if no user or binding references its previous principal ID anymore.
+func completeSnapshot(ctx context.Context, c *client.RancherClient, path, operation string, resources []*MigratableResource) error {
+	snapshot, err := LoadSnapshot(path)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		snapshot = Snapshot{}
+	}
+
+	users, err := GetUsersToMigrate(ctx, c)
+	if err != nil {
+		return err
+	}
+	bindings, err := GetUserBindings(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	for _, res := range resources {
+		if users[res.PrincipalID] != nil || len(bindings[res.PrincipalID]) > 0 {
+			continue
+		}
+
+		if operation == migrations.OperationRollback {
+			delete(snapshot, res.PrincipalID)
+			continue
+		}
+
+		updatedPrincipalID := GetUpdatedPrincipalID(res)
+		if entry, found := snapshot[updatedPrincipalID]; found {
+			entry.Status = SnapshotApplied
+			snapshot[updatedPrincipalID] = entry
+		}
+	}
+
+	return snapshot.Save(path)
+}
+
+// withSnapshot plans the snapshot of the principals before the update, and completes it after the update,
+// also if the update failed or was interrupted
+func withSnapshot(ctx context.Context, c *client.RancherClient, path, operation string, resources []*MigratableResource, update func() error) error {
+	if operation == migrations.OperationMigrate {
+		err := planSnapshot(path, resources)
+		if err != nil {
+			return err
+		}
+	}
+
+	updateErr := update()
+
+	err := completeSnapshot(context.WithoutCancel(ctx), c, path, operation, resources)
+	if err != nil {
+		err = fmt.Errorf("cannot complete snapshot: %w", err)
+		if updateErr != nil {
+			return fmt.Errorf("%w (%s)", updateErr, err)
+		}
+		return err
+	}
+
+	return updateErr
+}
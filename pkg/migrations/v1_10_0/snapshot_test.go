@@ -0,0 +1,154 @@
+package version_1_10_0
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+)
+
+func TestWithSnapshot(t *testing.T) {
+	f, c := newFakeRancher(t)
+
+	john := newTestUser(t, f, "u-john", "CN=john,DC=example,DC=com", "00112233-4455-6677-8899-aabbccddeeff")
+	jane := newTestUser(t, f, "u-jane", "CN=jane,DC=example,DC=com", "ffeeddcc-bbaa-9988-7766-554433221100")
+	resources := []*MigratableResource{john, jane}
+
+	// the update is interrupted after the first principal
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.onRequest = func(method, resource, name string) {
+		if method == http.MethodPut && resource == "users" {
+			cancel()
+		}
+	}
+
+	snapshotFile := filepath.Join(t.TempDir(), "snapshot.json")
+	opts := migrations.ApplyOptions{RunID: "run-1"}
+
+	err := withSnapshot(ctx, c, snapshotFile, migrations.OperationMigrate, resources, func() error {
+		return updateResources(ctx, c, migrations.OperationMigrate, resources, opts)
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("withSnapshot() error = %v, want %v", err, context.Canceled)
+	}
+
+	snapshot, err := LoadSnapshot(snapshotFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := snapshot[GetUpdatedPrincipalID(john)]; !entry.Applied() || entry.PRTBs != 1 || entry.User != "u-john" {
+		t.Errorf("snapshot entry of john = %+v, want applied with 1 PRTB", entry)
+	}
+	if entry := snapshot[GetUpdatedPrincipalID(jane)]; entry.Applied() {
+		t.Errorf("snapshot entry of jane = %+v, want planned", entry)
+	}
+
+	// the planned entry is not verified
+	m := &Migration{client: c}
+	m.opts.SnapshotFile = snapshotFile
+
+	result := snapshotCheck(t, m)
+	if result.Err != nil || !strings.Contains(result.Name, "1 migrated principals") {
+		t.Errorf("snapshotCheck() = %s: %v, want 1 principal verified", result.Name, result.Err)
+	}
+
+	// a lost binding is reported
+	for _, name := range f.names("projectroletemplatebindings", "p-1") {
+		err := c.Rancher.Delete().Resource("projectroletemplatebindings").Namespace("p-1").Name(name).Do(context.Background()).Error()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result = snapshotCheck(t, m)
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "PRTBs 0/1") {
+		t.Errorf("snapshotCheck() error = %v, want the missing PRTB", result.Err)
+	}
+}
+
+func TestWithSnapshotRollback(t *testing.T) {
+	f, c := newFakeRancher(t)
+
+	john := newTestUser(t, f, "u-john", "CN=john,DC=example,DC=com", "00112233-4455-6677-8899-aabbccddeeff")
+	resources := []*MigratableResource{john}
+
+	snapshotFile := filepath.Join(t.TempDir(), "snapshot.json")
+	err := Snapshot{
+		john.PrincipalID:  SnapshotEntry{PrincipalID: "activedirectory_user://objectGUID=00112233-4455-6677-8899-aabbccddeeff", PRTBs: 1},
+		"local://u-other": SnapshotEntry{PrincipalID: "local://u-other"},
+	}.Save(snapshotFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rollback failed: the entry is kept
+	failed := errors.New("failed")
+	err = withSnapshot(context.Background(), c, snapshotFile, migrations.OperationRollback, resources, func() error { return failed })
+	if !errors.Is(err, failed) {
+		t.Fatalf("withSnapshot() error = %v, want %v", err, failed)
+	}
+	snapshot, err := LoadSnapshot(snapshotFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := snapshot[john.PrincipalID]; !found || len(snapshot) != 2 {
+		t.Errorf("snapshot = %v, want unchanged", snapshot)
+	}
+
+	// the user is rolled back, but a PRTB still references the principal: the entry is kept
+	err = withSnapshot(context.Background(), c, snapshotFile, migrations.OperationRollback, resources, func() error {
+		return c.Rancher.Delete().Resource("users").Name("u-john").Do(context.Background()).Error()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err = LoadSnapshot(snapshotFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := snapshot[john.PrincipalID]; !found {
+		t.Errorf("snapshot = %v, want the principal referenced by a PRTB kept", snapshot)
+	}
+
+	// the rolled back principal is removed
+	err = withSnapshot(context.Background(), c, snapshotFile, migrations.OperationRollback, resources, func() error {
+		return c.Rancher.Delete().Resource("projectroletemplatebindings").Namespace("p-1").Name("prtb-u-john").Do(context.Background()).Error()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err = LoadSnapshot(snapshotFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := snapshot[john.PrincipalID]; found || len(snapshot) != 1 {
+		t.Errorf("snapshot = %v, want the rolled back principal removed", snapshot)
+	}
+}
+
+func snapshotCheck(t *testing.T, m *Migration) *migrations.CheckResult {
+	t.Helper()
+
+	users, err := GetUsersToMigrate(context.Background(), m.client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bindings, err := GetUserBindings(context.Background(), m.client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := m.snapshotCheck(users, bindings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil {
+		t.Fatal("snapshotCheck() = nil, want a result")
+	}
+	return result
+}
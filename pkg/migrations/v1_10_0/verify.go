@@ -0,0 +1,257 @@
+package version_1_10_0
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	"github.com/rancher/rancher/pkg/auth/providers/activedirectory/guid"
+)
+
+// maxListedPrincipals is the number of principals listed in the error of a failed check
+const maxListedPrincipals = 5
+
+// Verify checks that no principals in the DN format are left, that every objectGUID principal
+// exists in Active Directory, that the migrated principals kept the resources counted in the snapshot,
+// and that no bindings reference an objectGUID principal without a user.
+// The principals are not resolved with GetMigratableResources, since a principal not found
+// is a failed check and not an error.
+func (m *Migration) Verify(ctx context.Context) ([]migrations.CheckResult, error) {
+	logging.Println("Verifying resources")
+
+	users, err := GetUsersToMigrate(ctx, m.client)
+	if err != nil {
+		return nil, err
+	}
+
+	bindings, err := GetUserBindings(ctx, m.client)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []migrations.CheckResult
+
+	dnUsers := []string{}
+	for principalID := range users {
+		if !isGUIDPrincipal(principalID) {
+			dnUsers = append(dnUsers, principalID)
+		}
+	}
+	results = append(results, noPrincipalsCheck("users", dnUsers))
+
+	results = append(results,
+		noPrincipalsCheck("ProjectRoleTemplateBindings", dnBindings[*PRTBResource](bindings)),
+		noPrincipalsCheck("ClusterRoleTemplateBindings", dnBindings[*CRTBResource](bindings)),
+		noPrincipalsCheck("Tokens", dnBindings[*TokenResource](bindings)),
+	)
+
+	if m.opts.VerifyGroups {
+		groups, err := getDNGroupPrincipals(ctx, m.client)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, noPrincipalsCheck("group bindings", groups))
+	}
+
+	guidPrincipals := map[string]bool{}
+	for principalID := range users {
+		if isGUIDPrincipal(principalID) {
+			guidPrincipals[principalID] = true
+		}
+	}
+	for principalID := range bindings {
+		if isGUIDPrincipal(principalID) {
+			guidPrincipals[principalID] = true
+		}
+	}
+
+	resolver, err := m.getResolver(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := resolvableCheck(resolver, guidPrincipals)
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, result)
+
+	snapshotResult, err := m.snapshotCheck(users, bindings)
+	if err != nil {
+		return nil, err
+	}
+	if snapshotResult != nil {
+		results = append(results, *snapshotResult)
+	}
+
+	orphaned := []string{}
+	for principalID := range bindings {
+		if _, found := users[principalID]; !found && isGUIDPrincipal(principalID) {
+			orphaned = append(orphaned, principalID)
+		}
+	}
+	results = append(results, migrations.CheckResult{
+		Name: "no bindings of objectGUID principals without a user",
+		Err:  principalsError("orphaned", orphaned),
+	})
+
+	return results, nil
+}
+
+// resolvableCheck checks that the objectGUID principals exist in Active Directory
+func resolvableCheck(resolver Resolver, guidPrincipals map[string]bool) (migrations.CheckResult, error) {
+	objectGUIDPrincipalPrefix := fmt.Sprintf("%s://%s=", ad.UserScope, ad.ObjectGUIDAttribute)
+	result := migrations.CheckResult{Name: "objectGUID principals found in Active Directory"}
+
+	notFound := []string{}
+	for principalID := range guidPrincipals {
+		parsedGUID, err := guid.Parse(strings.TrimPrefix(principalID, objectGUIDPrincipalPrefix))
+		if err != nil {
+			notFound = append(notFound, principalID)
+			continue
+		}
+
+		_, err = resolver.GetDN(parsedGUID)
+		if err != nil {
+			if !errors.Is(err, errUserNotFound) {
+				return result, err
+			}
+			notFound = append(notFound, principalID)
+		}
+	}
+
+	result.Err = principalsError("not found", notFound)
+	return result, nil
+}
+
+// snapshotCheck checks that the migrated principals have at least the resources counted before their migration,
+// skipping the principals planned but not migrated. It returns nil if there is no snapshot.
+func (m *Migration) snapshotCheck(users map[string]*apiv3.User, bindings map[string][]PrincipalIDResource) (*migrations.CheckResult, error) {
+	if m.opts.SnapshotFile == "" {
+		return nil, nil
+	}
+
+	snapshot, err := LoadSnapshot(m.opts.SnapshotFile)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		logging.Printf("No snapshot found in '%s', resources count not verified\n", m.opts.SnapshotFile)
+		return nil, nil
+	}
+
+	var mismatches []string
+	var verified int
+	for principalID, entry := range snapshot {
+		// a planned principal is not verified while its previous principal ID is still referenced,
+		// i.e. its update failed or was interrupted
+		if !entry.Applied() && (users[entry.PrincipalID] != nil || len(bindings[entry.PrincipalID]) > 0) {
+			slog.Info("snapshot entry not applied", "principal", principalID, "previousPrincipal", entry.PrincipalID)
+			continue
+		}
+		verified++
+
+		current := &MigratableResource{
+			PrincipalID: principalID,
+			User:        users[principalID],
+			Bindings:    bindings[principalID],
+		}
+		actual := NewSnapshotEntry(current)
+
+		switch {
+		case entry.User != "" && actual.User == "":
+			mismatches = append(mismatches, fmt.Sprintf("%s: user %s not found", principalID, entry.User))
+		case actual.PRTBs < entry.PRTBs, actual.CRTBs < entry.CRTBs, actual.Tokens < entry.Tokens:
+			mismatches = append(mismatches, fmt.Sprintf(
+				"%s: PRTBs %d/%d, CRTBs %d/%d, Tokens %d/%d",
+				principalID,
+				actual.PRTBs, entry.PRTBs, actual.CRTBs, entry.CRTBs, actual.Tokens, entry.Tokens,
+			))
+		}
+	}
+
+	return &migrations.CheckResult{
+		Name: fmt.Sprintf("resources of the %d migrated principals match the snapshot", verified),
+		Err:  principalsError("with missing resources", mismatches),
+	}, nil
+}
+
+// getDNGroupPrincipals returns the group principals in the DN format of the PRTBs and CRTBs
+func getDNGroupPrincipals(ctx context.Context, c *client.RancherClient) ([]string, error) {
+	groups := []string{}
+
+	prtbs := &apiv3.ProjectRoleTemplateBindingList{}
+	err := c.Rancher.Get().Resource("projectroletemplatebindings").Do(ctx).Into(prtbs)
+	if err != nil {
+		return nil, err
+	}
+	for _, prtb := range prtbs.Items {
+		groups = append(groups, prtb.GroupPrincipalName)
+	}
+
+	crtbs := &apiv3.ClusterRoleTemplateBindingList{}
+	err = c.Rancher.Get().Resource("clusterroletemplatebindings").Do(ctx).Into(crtbs)
+	if err != nil {
+		return nil, err
+	}
+	for _, crtb := range crtbs.Items {
+		groups = append(groups, crtb.GroupPrincipalName)
+	}
+
+	groups = slices.DeleteFunc(groups, func(principalID string) bool {
+		return !strings.HasPrefix(principalID, ad.GroupScope+"://") || strings.Contains(principalID, ad.ObjectGUIDAttribute)
+	})
+	slices.Sort(groups)
+
+	return slices.Compact(groups), nil
+}
+
+// dnBindings returns the principals in the DN format of the bindings of a type
+func dnBindings[T PrincipalIDResource](bindings map[string][]PrincipalIDResource) []string {
+	principals := []string{}
+	for principalID, binds := range bindings {
+		if !isGUIDPrincipal(principalID) && len(GetResourceByType[T](binds)) > 0 {
+			principals = append(principals, principalID)
+		}
+	}
+	return principals
+}
+
+func noPrincipalsCheck(kind string, dnPrincipals []string) migrations.CheckResult {
+	return migrations.CheckResult{
+		Name: "no principals in the DN format in " + kind,
+		Err:  principalsError("found", dnPrincipals),
+	}
+}
+
+// principalsError returns an error listing the first principals, or nil if there are none
+func principalsError(reason string, principals []string) error {
+	if len(principals) == 0 {
+		return nil
+	}
+
+	slices.Sort(principals)
+
+	listed := principals
+	if len(listed) > maxListedPrincipals {
+		listed = listed[:maxListedPrincipals]
+	}
+
+	msg := fmt.Sprintf("%d %s: %s", len(principals), reason, strings.Join(listed, ", "))
+	if len(principals) > len(listed) {
+		msg += ", ..."
+	}
+	return errors.New(msg)
+}
+
+func isGUIDPrincipal(principalID string) bool {
+	return strings.Contains(principalID, ad.ObjectGUIDAttribute)
+}
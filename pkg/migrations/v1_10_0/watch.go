@@ -0,0 +1,280 @@
+package version_1_10_0
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	ad "github.com/rancher/rancher/pkg/auth/providers/activedirectory"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// watchSettle is how long the watch waits for the objects of a new principal (i.e. the user and its token
+// created by a login) before migrating it
+const watchSettle = 5 * time.Second
+
+// principalWatcher collects the principals in the DN format of the users, bindings and tokens seen by the informers.
+// The planned principals are known: all the others are pending, also the ones seen before the informers are synced,
+// since they could have been created by a login after the discovery (i.e. while the plan was being confirmed).
+type principalWatcher struct {
+	mu      sync.Mutex
+	known   map[string]bool
+	pending map[string]bool
+	notify  chan struct{}
+}
+
+func newPrincipalWatcher(planned []string) *principalWatcher {
+	w := &principalWatcher{
+		known:   map[string]bool{},
+		pending: map[string]bool{},
+		notify:  make(chan struct{}, 1),
+	}
+	for _, principalID := range planned {
+		w.known[principalID] = true
+	}
+	return w
+}
+
+// add records the principals in the DN format of an object
+func (w *principalWatcher) add(obj any) {
+	var principalIDs []string
+
+	switch o := obj.(type) {
+	case *apiv3.User:
+		principalIDs = o.PrincipalIDs
+	case *apiv3.ProjectRoleTemplateBinding:
+		principalIDs = []string{o.UserPrincipalName}
+	case *apiv3.ClusterRoleTemplateBinding:
+		principalIDs = []string{o.UserPrincipalName}
+	case *apiv3.Token:
+		principalIDs = []string{o.UserPrincipal.Name}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, principalID := range principalIDs {
+		if !isDNPrincipal(principalID) || w.known[principalID] || w.pending[principalID] {
+			continue
+		}
+
+		w.pending[principalID] = true
+		slog.Info("late principal detected", "principal", principalID)
+
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// take returns the pending principals, that become known
+func (w *principalWatcher) take() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	principalIDs := make([]string, 0, len(w.pending))
+	for principalID := range w.pending {
+		principalIDs = append(principalIDs, principalID)
+		w.known[principalID] = true
+	}
+	clear(w.pending)
+
+	slices.Sort(principalIDs)
+	return principalIDs
+}
+
+// isDNPrincipal returns true if the principal is an Active Directory user in the DN format
+func isDNPrincipal(principalID string) bool {
+	return strings.HasPrefix(principalID, ad.UserScope+"://") && !strings.Contains(principalID, ad.ObjectGUIDAttribute)
+}
+
+// startInformers starts the informers of the users, bindings and tokens, waiting for their initial sync
+func startInformers(ctx context.Context, c *client.RancherClient, w *principalWatcher) error {
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    w.add,
+		UpdateFunc: func(_, obj any) { w.add(obj) },
+	}
+
+	var synced []cache.InformerSynced
+
+	for _, informed := range []struct {
+		resource string
+		object   runtime.Object
+		newList  func() runtime.Object
+	}{
+		{"users", &apiv3.User{}, func() runtime.Object { return &apiv3.UserList{} }},
+		{"projectroletemplatebindings", &apiv3.ProjectRoleTemplateBinding{}, func() runtime.Object { return &apiv3.ProjectRoleTemplateBindingList{} }},
+		{"clusterroletemplatebindings", &apiv3.ClusterRoleTemplateBinding{}, func() runtime.Object { return &apiv3.ClusterRoleTemplateBindingList{} }},
+		{"tokens", &apiv3.Token{}, func() runtime.Object { return &apiv3.TokenList{} }},
+	} {
+		// the lists and the events are decoded into their typed objects, as the Rancher client cannot decode them
+		// into the internal version
+		lw := &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				list := informed.newList()
+				err := c.Rancher.Get().Resource(informed.resource).
+					VersionedParams(&options, metav1.ParameterCodec).
+					Do(ctx).
+					Into(list)
+				return list, err
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return c.Watch(ctx, informed.resource, options)
+			},
+		}
+		informer := cache.NewSharedIndexInformer(lw, informed.object, 0, cache.Indexers{})
+
+		_, err := informer.AddEventHandler(handler)
+		if err != nil {
+			return fmt.Errorf("cannot watch %s: %w", informed.resource, err)
+		}
+
+		go informer.Run(ctx.Done())
+		synced = append(synced, informer.HasSynced)
+	}
+
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return fmt.Errorf("cannot sync the informers: %w", ctx.Err())
+	}
+
+	return nil
+}
+
+// updateAndWatch updates the resources, and then for the Watch duration migrates the principals in the DN format
+// that were not planned, i.e. created by the logins of new users after the discovery.
+// The informers are started before the update, so that no principal appearing meanwhile is missed.
+func (m *Migration) updateAndWatch(ctx context.Context, operation string, resources []*MigratableResource, opts migrations.ApplyOptions) error {
+	if operation != migrations.OperationMigrate {
+		return fmt.Errorf("the %s cannot watch the new principals", operation)
+	}
+
+	informersCtx, stopInformers := context.WithCancel(ctx)
+	defer stopInformers()
+
+	w := newPrincipalWatcher(principalIDs(resources))
+	err := startInformers(informersCtx, m.client, w)
+	if err != nil {
+		return err
+	}
+
+	err = updateResources(ctx, m.client, operation, resources, opts)
+	if err != nil {
+		return err
+	}
+
+	logging.Printf("Watching the new principals for %s\n", opts.Watch)
+	slog.Info("watch started", "duration", opts.Watch)
+
+	deadline := time.After(opts.Watch)
+	var late, failed []string
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("watch interrupted: %w", ctx.Err())
+
+		case <-deadline:
+			slog.Info("watch completed", "late", len(late), "failed", len(failed))
+			logging.Printf("Watch completed: %d late principals found, %d failed\n", len(late), len(failed))
+
+			if len(failed) > 0 {
+				return fmt.Errorf("%d late principals failed: %s", len(failed), strings.Join(failed, ", "))
+			}
+			return nil
+
+		case <-w.notify:
+		}
+
+		// wait for the other objects of the new principals
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("watch interrupted: %w", ctx.Err())
+		case <-time.After(watchSettle):
+		}
+
+		principalIDs := w.take()
+		late = append(late, principalIDs...)
+
+		failed = append(failed, m.migrateLate(ctx, principalIDs, opts)...)
+	}
+}
+
+// migrateLate migrates the late principals, returning the failed ones. The objects are listed again,
+// to update them at their latest version. Every principal is resolved and updated on its own,
+// so that a principal not found in Active Directory does not stop the others.
+func (m *Migration) migrateLate(ctx context.Context, principalIDs []string, opts migrations.ApplyOptions) []string {
+	var failed []string
+
+	fail := func(principalID string, err error) {
+		slog.Error("late principal failed", "principal", principalID, "error", err)
+		logging.Printf("%s: %s: %s\n", red("failed"), principalID, err)
+		failed = append(failed, principalID)
+	}
+
+	resolver, err := m.getResolver(ctx)
+	if err != nil {
+		for _, principalID := range principalIDs {
+			fail(principalID, err)
+		}
+		return failed
+	}
+
+	migratable, err := ListMigratableResources(ctx, m.client)
+	if err != nil {
+		for _, principalID := range principalIDs {
+			fail(principalID, err)
+		}
+		return failed
+	}
+
+	for _, principalID := range principalIDs {
+		res, found := migratable[principalID]
+		if !found {
+			slog.Info("late principal gone", "principal", principalID)
+			continue
+		}
+
+		logging.Printf("=== Late principal %s ===\n", yellow(principalID))
+		logResource(res)
+
+		late := MigratableResources{principalID: res}
+
+		err := late.Resolve(resolver)
+		if err != nil {
+			fail(principalID, err)
+			continue
+		}
+		late.applyPolicy(m.policy)
+
+		allowed := m.skipRancherMigrated(protect([]*MigratableResource{res}, nil))
+		if len(allowed) == 0 {
+			continue
+		}
+
+		update := func() error {
+			return updateResourceStep(ctx, m.client, res, opts)
+		}
+		if m.opts.SnapshotFile != "" {
+			err = withSnapshot(ctx, m.client, m.opts.SnapshotFile, migrations.OperationMigrate, allowed, update)
+		} else {
+			err = update()
+		}
+		if err != nil {
+			fail(principalID, err)
+		}
+	}
+
+	return failed
+}
@@ -0,0 +1,75 @@
+package version_1_10_0
+
+import (
+	"slices"
+	"testing"
+
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+)
+
+func TestPrincipalWatcher(t *testing.T) {
+	const (
+		planned  = "activedirectory_user://CN=Planned,DC=example,DC=com"
+		late     = "activedirectory_user://CN=Late,DC=example,DC=com"
+		migrated = "activedirectory_user://objectGUID=00112233-4455-6677-8899-aabbccddeeff"
+		local    = "local://u-abc123"
+	)
+
+	w := newPrincipalWatcher([]string{planned})
+
+	// the objects listed by the initial sync of the informers, including a principal
+	// created by a login after the discovery
+	w.add(&apiv3.User{PrincipalIDs: []string{local, planned}})
+	w.add(&apiv3.User{PrincipalIDs: []string{local, late}})
+	w.add(&apiv3.ProjectRoleTemplateBinding{UserPrincipalName: migrated})
+
+	select {
+	case <-w.notify:
+	default:
+		t.Fatal("late principal seen before the sync not notified")
+	}
+	if got := w.take(); !slices.Equal(got, []string{late}) {
+		t.Fatalf("take() = %v, want %v", got, []string{late})
+	}
+
+	// the taken principals are not pending again
+	w.add(&apiv3.ClusterRoleTemplateBinding{UserPrincipalName: late})
+	if got := w.take(); len(got) != 0 {
+		t.Fatalf("take() = %v, want no principals", got)
+	}
+
+	// a principal appearing after the sync
+	newer := "activedirectory_user://CN=Newer,DC=example,DC=com"
+	token := &apiv3.Token{}
+	token.UserPrincipal.Name = newer
+	w.add(token)
+	w.add(&apiv3.ClusterRoleTemplateBinding{UserPrincipalName: newer})
+
+	select {
+	case <-w.notify:
+	default:
+		t.Fatal("late principal seen after the sync not notified")
+	}
+	if got := w.take(); !slices.Equal(got, []string{newer}) {
+		t.Fatalf("take() = %v, want %v", got, []string{newer})
+	}
+}
+
+func TestIsDNPrincipal(t *testing.T) {
+	tests := []struct {
+		principalID string
+		want        bool
+	}{
+		{"activedirectory_user://CN=John Doe,DC=example,DC=com", true},
+		{"activedirectory_user://objectGUID=00112233-4455-6677-8899-aabbccddeeff", false},
+		{"activedirectory_group://CN=Admins,DC=example,DC=com", false},
+		{"local://u-abc123", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isDNPrincipal(tt.principalID); got != tt.want {
+			t.Errorf("isDNPrincipal(%q) = %t, want %t", tt.principalID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package version_1_10_0
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/client"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/logging"
+	"github.com/enrichman/kubectl-rancher_migrate/pkg/migrations"
+)
+
+// updateWaves updates the resources in waves. A failed principal does not stop its wave: after every wave
+// the updated principals are verified, and the update is stopped if the rate of the failed principals
+// of the wave exceeds the MaxFailureRate. The failed and the not updated principals are saved
+// in the StateFile with the boundaries of their waves.
+func updateWaves(ctx context.Context, c *client.RancherClient, operation string, resources []*MigratableResource, opts migrations.ApplyOptions) error {
+	byID := map[string]*MigratableResource{}
+	for _, res := range resources {
+		byID[res.PrincipalID] = res
+	}
+
+	waves := opts.SplitWaves(principalIDs(resources))
+
+	var (
+		completed []string
+		// failed are the failed principals of the completed waves, retried with the first pending wave
+		failed []string
+	)
+
+	// stop saves the state with the failed principals and the pending waves
+	stop := func(wave int, pending []string, reason error) error {
+		pendingWaves := append([][]string{slices.Concat(failed, pending)}, waves[wave+1:]...)
+		return saveWavesState(operation, completed, pendingWaves, opts, reason)
+	}
+
+	for i, wave := range waves {
+		logging.Printf("=== Wave %d/%d (%d principals) ===\n", i+1, len(waves), len(wave))
+		slog.Info("wave started", "wave", i+1, "waves", len(waves), "principals", len(wave))
+
+		var updated, waveFailed []string
+
+		for j, principalID := range wave {
+			if err := ctx.Err(); err != nil {
+				err = fmt.Errorf("update interrupted in wave %d/%d: %w", i+1, len(waves), err)
+				return stop(i, append(waveFailed, wave[j:]...), err)
+			}
+
+			logging.Printf("--- (%02d/%02d) ---\n", j+1, len(wave))
+
+			err := updateResourceStep(ctx, c, byID[principalID], opts)
+			if err != nil {
+				slog.Error("principal update failed", "principal", principalID, "wave", i+1, "error", err)
+				logging.Printf("%s: %s\n", red("failed"), err)
+				waveFailed = append(waveFailed, principalID)
+				continue
+			}
+			updated = append(updated, principalID)
+		}
+
+		verifyFailed, err := verifyWave(ctx, c, updated, byID)
+		if err != nil {
+			return stop(i, append(waveFailed, updated...), fmt.Errorf("cannot verify wave %d/%d: %w", i+1, len(waves), err))
+		}
+
+		for _, principalID := range updated {
+			if verifyErr, found := verifyFailed[principalID]; found {
+				slog.Error("principal verification failed", "principal", principalID, "wave", i+1, "error", verifyErr)
+				logging.Printf("%s: %s: %s\n", red("verification failed"), principalID, verifyErr)
+				waveFailed = append(waveFailed, principalID)
+				continue
+			}
+			completed = append(completed, principalID)
+		}
+		failed = append(failed, waveFailed...)
+
+		failureRate := float64(len(waveFailed)) / float64(len(wave))
+		slog.Info("wave completed", "wave", i+1, "waves", len(waves), "failed", len(waveFailed), "failureRate", failureRate)
+		logging.Printf(
+			"=== Wave %d/%d completed: %d updated, %d failed (%.0f%%) ===\n",
+			i+1, len(waves), len(wave)-len(waveFailed), len(waveFailed), failureRate*100,
+		)
+
+		if failureRate > opts.MaxFailureRate {
+			err := fmt.Errorf(
+				"wave %d/%d stopped: failure rate %.0f%% exceeds %.0f%%",
+				i+1, len(waves), failureRate*100, opts.MaxFailureRate*100,
+			)
+			return stop(i, nil, err)
+		}
+
+		if i < len(waves)-1 && opts.Pause > 0 {
+			logging.Printf("Pausing for %s before the next wave\n", opts.Pause)
+
+			select {
+			case <-ctx.Done():
+				return stop(i, nil, fmt.Errorf("update interrupted after wave %d/%d: %w", i+1, len(waves), ctx.Err()))
+			case <-time.After(opts.Pause):
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return stop(len(waves)-1, nil, fmt.Errorf("%d principals failed", len(failed)))
+	}
+	return nil
+}
+
+// saveWavesState saves the pending waves in the StateFile, returning the reason of the interruption
+func saveWavesState(operation string, completed []string, pending [][]string, opts migrations.ApplyOptions, reason error) error {
+	if opts.StateFile == "" {
+		return reason
+	}
+
+	state := migrations.NewWavesState(operation, completed, pending, reason)
+
+	err := state.Save(opts.StateFile)
+	if err != nil {
+		return fmt.Errorf("%w (cannot save state: %s)", reason, err)
+	}
+
+	slog.Warn("state saved", "operation", operation, "pending", len(state.Pending), "waves", len(state.Waves), "stateFile", opts.StateFile)
+	logging.Printf(
+		"%d principals pending in %d waves, state saved in '%s': run the %s again with --resume to continue\n",
+		len(state.Pending), len(state.Waves), opts.StateFile, operation,
+	)
+
+	return reason
+}
+
+// verifyWave checks that the updated principals have no resources left, and that their updated principals
+// have at least their user and as many bindings and tokens. It returns the failed checks by principal.
+func verifyWave(ctx context.Context, c *client.RancherClient, updated []string, byID map[string]*MigratableResource) (map[string]error, error) {
+	failed := map[string]error{}
+	if len(updated) == 0 {
+		return failed, nil
+	}
+
+	users, err := GetUsersToMigrate(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	bindings, err := GetUserBindings(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, principalID := range updated {
+		res := byID[principalID]
+		updatedPrincipalID := GetUpdatedPrincipalID(res)
+
+		var errs []error
+
+		if _, found := users[principalID]; found {
+			errs = append(errs, errors.New("user still has the previous principal"))
+		}
+		if left := len(bindings[principalID]); left > 0 {
+			errs = append(errs, fmt.Errorf("%d bindings or tokens still reference the previous principal", left))
+		}
+		if _, found := users[updatedPrincipalID]; res.User != nil && !found {
+			errs = append(errs, errors.New("user without the updated principal"))
+		}
+
+		expected := NewSnapshotEntry(res)
+		actual := NewSnapshotEntry(&MigratableResource{Bindings: bindings[updatedPrincipalID]})
+
+		if actual.PRTBs < expected.PRTBs {
+			errs = append(errs, fmt.Errorf("%d of %d ProjectRoleTemplateBindings", actual.PRTBs, expected.PRTBs))
+		}
+		if actual.CRTBs < expected.CRTBs {
+			errs = append(errs, fmt.Errorf("%d of %d ClusterRoleTemplateBindings", actual.CRTBs, expected.CRTBs))
+		}
+		if actual.Tokens < expected.Tokens {
+			errs = append(errs, fmt.Errorf("%d of %d Tokens", actual.Tokens, expected.Tokens))
+		}
+
+		if len(errs) > 0 {
+			failed[principalID] = errors.Join(errs...)
+		}
+	}
+
+	return failed, nil
+}
@@ -0,0 +1,49 @@
+package migrations
+
+// Phased returns true if the items are applied in waves, verified after each of them
+func (o ApplyOptions) Phased() bool {
+	return o.BatchSize > 0 || len(o.Waves) > 0
+}
+
+// SplitWaves returns the IDs grouped in the Waves, or in batches of BatchSize items.
+// The IDs not listed in the Waves are applied in a last wave, and the empty waves are dropped.
+func (o ApplyOptions) SplitWaves(ids []string) [][]string {
+	if len(o.Waves) == 0 {
+		if o.BatchSize <= 0 {
+			return [][]string{ids}
+		}
+
+		var waves [][]string
+		for start := 0; start < len(ids); start += o.BatchSize {
+			end := min(start+o.BatchSize, len(ids))
+			waves = append(waves, ids[start:end])
+		}
+		return waves
+	}
+
+	waveOf := map[string]int{}
+	for i, wave := range o.Waves {
+		for _, id := range wave {
+			if _, found := waveOf[id]; !found {
+				waveOf[id] = i
+			}
+		}
+	}
+
+	grouped := make([][]string, len(o.Waves)+1)
+	for _, id := range ids {
+		i, found := waveOf[id]
+		if !found {
+			i = len(o.Waves)
+		}
+		grouped[i] = append(grouped[i], id)
+	}
+
+	var waves [][]string
+	for _, wave := range grouped {
+		if len(wave) > 0 {
+			waves = append(waves, wave)
+		}
+	}
+	return waves
+}
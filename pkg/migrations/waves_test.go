@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitWaves(t *testing.T) {
+	ids := []string{"a", "b", "c", "d", "e"}
+
+	tests := []struct {
+		name string
+		opts ApplyOptions
+		ids  []string
+		want [][]string
+	}{
+		{
+			name: "single wave",
+			ids:  ids,
+			want: [][]string{{"a", "b", "c", "d", "e"}},
+		},
+		{
+			name: "batches",
+			opts: ApplyOptions{BatchSize: 2},
+			ids:  ids,
+			want: [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+		},
+		{
+			name: "batch larger than the items",
+			opts: ApplyOptions{BatchSize: 10},
+			ids:  ids,
+			want: [][]string{{"a", "b", "c", "d", "e"}},
+		},
+		{
+			name: "waves in the order of the ids",
+			opts: ApplyOptions{Waves: [][]string{{"c", "a"}, {"e", "d", "b"}}},
+			ids:  ids,
+			want: [][]string{{"a", "c"}, {"b", "d", "e"}},
+		},
+		{
+			name: "ids not listed in any wave are applied last",
+			opts: ApplyOptions{Waves: [][]string{{"b"}, {"d"}}},
+			ids:  ids,
+			want: [][]string{{"b"}, {"d"}, {"a", "c", "e"}},
+		},
+		{
+			name: "ids of the waves not planned and empty waves are dropped",
+			opts: ApplyOptions{Waves: [][]string{{"x"}, {}, {"a", "y"}, {"b"}}},
+			ids:  []string{"a", "b"},
+			want: [][]string{{"a"}, {"b"}},
+		},
+		{
+			name: "id listed in two waves is applied in the first one",
+			opts: ApplyOptions{Waves: [][]string{{"a"}, {"a", "b"}}},
+			ids:  []string{"a", "b"},
+			want: [][]string{{"a"}, {"b"}},
+		},
+		{
+			name: "waves take precedence over the batch size",
+			opts: ApplyOptions{BatchSize: 1, Waves: [][]string{{"a", "b"}}},
+			ids:  []string{"a", "b"},
+			want: [][]string{{"a", "b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.opts.SplitWaves(tt.ids)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitWaves() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}